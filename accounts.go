@@ -0,0 +1,336 @@
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrAccountMismatch indicates that the service user or group already exists
+	// with settings that differ from the requested ones (UID, GID, primary group, home).
+	ErrAccountMismatch = errors.New("account exists with different settings")
+
+	// ErrAccountCreate indicates that creating or updating the service user or group failed.
+	ErrAccountCreate = errors.New("account creation failed")
+)
+
+// defaultHomeMode is the permission applied to a service home directory when none is configured.
+const defaultHomeMode = 0o750
+
+// AccountConfig describes the service account beyond its user and group names.
+// Zero values leave the corresponding setting to the system defaults.
+type AccountConfig struct {
+	UID    int      // Fixed user ID (0 to allocate dynamically)
+	GID    int      // Fixed group ID (0 to allocate dynamically)
+	GECOS  string   // Account description
+	Home   string   // Home directory, an absolute path without whitespace or quotes
+	Shell  string   // Login shell, an absolute path (defaults to /usr/sbin/nologin)
+	Groups []string // Supplementary groups (e.g. "adm", "systemd-journal")
+
+	HomeMode os.FileMode // Home directory permissions (defaults to 0750; ignored by sysusers.d)
+}
+
+// WithAccount configures how the service account is provisioned natively:
+// fixed IDs, supplementary groups, home directory and shell. The group is created
+// first and used as the user's primary group.
+func WithAccount(acct AccountConfig) ServiceOpt {
+	return func(c *ServiceConfig) {
+		if err := acct.validate(); err != nil {
+			c.invalid("account", err)
+			return
+		}
+		c.Account = &acct
+	}
+}
+
+// WithSysusers declares the service account through sysusers.d instead of
+// useradd/groupadd. Install writes /etc/sysusers.d/<UniqueName>.conf and applies
+// it with systemd-sysusers; Uninstall removes the file but keeps the accounts.
+func WithSysusers(acct AccountConfig) ServiceOpt {
+	return func(c *ServiceConfig) {
+		if err := acct.validate(); err != nil {
+			c.invalid("sysusers", err)
+			return
+		}
+		c.Account = &acct
+		c.UseSysusers = true
+	}
+}
+
+// validate checks the account settings for values that cannot be rendered safely.
+func (a *AccountConfig) validate() error {
+	if a.UID < 0 || a.GID < 0 {
+		return fmt.Errorf("negative UID/GID")
+	}
+	if strings.ContainsAny(a.GECOS, "\"\n:") {
+		return fmt.Errorf("invalid GECOS %q", a.GECOS)
+	}
+	if a.Home != "" && !accountPathOK(a.Home) {
+		return fmt.Errorf("invalid home directory %q", a.Home)
+	}
+	if a.Shell != "" && !accountPathOK(a.Shell) {
+		return fmt.Errorf("invalid shell %q", a.Shell)
+	}
+	for _, g := range a.Groups {
+		if g == "" || strings.ContainsAny(g, " \t\n:") {
+			return fmt.Errorf("invalid supplementary group %q", g)
+		}
+	}
+	return nil
+}
+
+// accountPathOK reports whether path is absolute and can be written unquoted
+// into a sysusers.d line and passed to useradd: no whitespace, quotes, colons
+// or control characters.
+func accountPathOK(path string) bool {
+	if !filepath.IsAbs(path) {
+		return false
+	}
+	return !strings.ContainsFunc(path, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune(`"':`, r)
+	})
+}
+
+// sysusersPath returns the file path for the sysusers.d configuration.
+func sysusersPath(c *ServiceConfig) string {
+	return fmt.Sprintf("/etc/sysusers.d/%s.conf", c.UniqueName)
+}
+
+// renderSysusersConf generates the sysusers.d entries for the service account.
+// The group is declared before the user so it becomes the user's primary group.
+func renderSysusersConf(c *ServiceConfig) string {
+	a := c.Account
+	if a == nil {
+		a = &AccountConfig{}
+	}
+
+	orDash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	id := func(n int) string {
+		if n == 0 {
+			return "-"
+		}
+		return strconv.Itoa(n)
+	}
+
+	gecos := "-"
+	if a.GECOS != "" {
+		gecos = strconv.Quote(a.GECOS)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Service account for %s\n", c.ServiceName)
+	fmt.Fprintf(&b, "g %s %s\n", c.Group, id(a.GID))
+	fmt.Fprintf(&b, "u %s %s:%s %s %s %s\n", c.User, id(a.UID), c.Group,
+		gecos, orDash(a.Home), orDash(a.Shell))
+	for _, g := range a.Groups {
+		fmt.Fprintf(&b, "m %s %s\n", c.User, g)
+	}
+	return b.String()
+}
+
+// writeSysusersConf writes the sysusers.d configuration and applies it.
+func writeSysusersConf(c *ServiceConfig) error {
+	path := sysusersPath(c)
+	if err := c.writeFile(path, []byte(renderSysusersConf(c))); err != nil {
+		return err
+	}
+	if err := c.execCommand("systemd-sysusers", path); err != nil {
+		return fmt.Errorf("failed to apply %s: %w", path, err)
+	}
+	return nil
+}
+
+// ensureServiceAccount creates the service group and user if they don't exist.
+// The group is created first and becomes the user's primary group; supplementary
+// groups and the home directory are applied to new and existing users alike.
+// An existing account whose UID, primary group or home differs from the
+// configuration is reported as ErrAccountMismatch rather than modified.
+func ensureServiceAccount(c *ServiceConfig) error {
+	a := c.Account
+	if a == nil {
+		a = &AccountConfig{}
+	}
+
+	gid, err := ensureGroup(c, c.Group, a.GID)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range a.Groups {
+		if _, err := user.LookupGroup(g); err != nil {
+			return fmt.Errorf("%w: supplementary group %s: %w", ErrAccountCreate, g, err)
+		}
+	}
+
+	u, err := user.Lookup(c.User)
+	var unknown user.UnknownUserError
+	switch {
+	case errors.As(err, &unknown):
+		if u, err = createUser(c, c.User, c.Group, a); err != nil {
+			return err
+		}
+	case err != nil:
+		return fmt.Errorf("failed to look up user %s: %w", c.User, err)
+	default:
+		if err := checkExistingUser(u, gid, a); err != nil {
+			return err
+		}
+		if err := addSupplementaryGroups(c, u, a.Groups); err != nil {
+			return err
+		}
+	}
+
+	if a.Home != "" {
+		return ensureHome(a.Home, a.HomeMode, u)
+	}
+	return nil
+}
+
+// ensureGroup returns the GID of the named group, creating it as a system group if needed.
+func ensureGroup(c *ServiceConfig, name string, gid int) (string, error) {
+	g, err := user.LookupGroup(name)
+	if err == nil {
+		if gid != 0 && g.Gid != strconv.Itoa(gid) {
+			return "", fmt.Errorf("%w: group %s has GID %s, expected %d", ErrAccountMismatch, name, g.Gid, gid)
+		}
+		return g.Gid, nil
+	}
+
+	var unknown user.UnknownGroupError
+	if !errors.As(err, &unknown) {
+		return "", fmt.Errorf("failed to look up group %s: %w", name, err)
+	}
+
+	args := []string{"--system"}
+	if gid != 0 {
+		args = append(args, "--gid", strconv.Itoa(gid))
+	}
+	if err := c.execCommand("groupadd", append(args, name)...); err != nil {
+		return "", fmt.Errorf("%w: group %s: %w", ErrAccountCreate, name, err)
+	}
+
+	if g, err = user.LookupGroup(name); err != nil {
+		return "", fmt.Errorf("%w: group %s: %w", ErrAccountCreate, name, err)
+	}
+	return g.Gid, nil
+}
+
+// createUser creates a system user with the given primary group and account settings.
+func createUser(c *ServiceConfig, name, group string, a *AccountConfig) (*user.User, error) {
+	shell := a.Shell
+	if shell == "" {
+		shell = "/usr/sbin/nologin"
+	}
+
+	args := []string{"--system", "--gid", group, "--shell", shell, "--no-create-home"}
+	if a.UID != 0 {
+		args = append(args, "--uid", strconv.Itoa(a.UID))
+	}
+	if a.GECOS != "" {
+		args = append(args, "--comment", a.GECOS)
+	}
+	if a.Home != "" {
+		args = append(args, "--home-dir", a.Home)
+	}
+	if len(a.Groups) > 0 {
+		args = append(args, "--groups", strings.Join(a.Groups, ","))
+	}
+
+	if err := c.execCommand("useradd", append(args, name)...); err != nil {
+		return nil, fmt.Errorf("%w: user %s: %w", ErrAccountCreate, name, err)
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: user %s: %w", ErrAccountCreate, name, err)
+	}
+	return u, nil
+}
+
+// checkExistingUser compares an existing user against the requested settings.
+func checkExistingUser(u *user.User, gid string, a *AccountConfig) error {
+	switch {
+	case u.Gid != gid:
+		return fmt.Errorf("%w: user %s has primary GID %s, expected %s", ErrAccountMismatch, u.Username, u.Gid, gid)
+	case a.UID != 0 && u.Uid != strconv.Itoa(a.UID):
+		return fmt.Errorf("%w: user %s has UID %s, expected %d", ErrAccountMismatch, u.Username, u.Uid, a.UID)
+	case a.Home != "" && u.HomeDir != a.Home:
+		return fmt.Errorf("%w: user %s has home %s, expected %s", ErrAccountMismatch, u.Username, u.HomeDir, a.Home)
+	}
+	return nil
+}
+
+// addSupplementaryGroups adds the user to any of the given groups it is not yet a member of.
+func addSupplementaryGroups(c *ServiceConfig, u *user.User, groups []string) error {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	current, err := u.GroupIds()
+	if err != nil {
+		return fmt.Errorf("failed to list groups of user %s: %w", u.Username, err)
+	}
+
+	var missing []string
+	for _, name := range groups {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return fmt.Errorf("%w: supplementary group %s: %w", ErrAccountCreate, name, err)
+		}
+		if !slices.Contains(current, g.Gid) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if err := c.execCommand("usermod", "--append", "--groups", strings.Join(missing, ","), u.Username); err != nil {
+		return fmt.Errorf("%w: user %s: %w", ErrAccountCreate, u.Username, err)
+	}
+	return nil
+}
+
+// ensureHome creates the home directory owned by the user and applies its mode.
+func ensureHome(dir string, mode os.FileMode, u *user.User) error {
+	if mode == 0 {
+		mode = defaultHomeMode
+	}
+
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("invalid UID %q for user %s: %w", u.Uid, u.Username, err)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("invalid GID %q for user %s: %w", u.Gid, u.Username, err)
+	}
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, mode); err != nil {
+			return fmt.Errorf("%w: home %s: %w", ErrAccountCreate, dir, err)
+		}
+		if err := os.Chown(dir, uid, gid); err != nil {
+			return fmt.Errorf("%w: home %s: %w", ErrAccountCreate, dir, err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(dir, mode); err != nil {
+		return fmt.Errorf("%w: home %s: %w", ErrAccountCreate, dir, err)
+	}
+	return nil
+}
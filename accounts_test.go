@@ -0,0 +1,154 @@
+package systemd
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+)
+
+// TestRenderSysusersConf tests sysusers.d entry generation
+func TestRenderSysusersConf(t *testing.T) {
+	cfg := ServiceConfig{
+		User:        "svc",
+		Group:       "svcgrp",
+		ServiceName: "svc.service",
+	}
+
+	// Dynamic IDs and defaults
+	expected := "# Service account for svc.service\n" +
+		"g svcgrp -\n" +
+		"u svc -:svcgrp - - -\n"
+	if got := renderSysusersConf(&cfg); got != expected {
+		t.Errorf("Sysusers content mismatch.\nExpected:\n%s\nGot:\n%s", expected, got)
+	}
+
+	// Fixed IDs, GECOS, home, shell and supplementary groups
+	WithSysusers(AccountConfig{
+		UID:    812,
+		GID:    813,
+		GECOS:  "My Service",
+		Home:   "/var/lib/svc",
+		Shell:  "/usr/sbin/nologin",
+		Groups: []string{"adm", "systemd-journal"},
+	})(&cfg)
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	if !cfg.UseSysusers {
+		t.Error("Expected UseSysusers to be true")
+	}
+
+	expected = "# Service account for svc.service\n" +
+		"g svcgrp 813\n" +
+		"u svc 812:svcgrp \"My Service\" /var/lib/svc /usr/sbin/nologin\n" +
+		"m svc adm\n" +
+		"m svc systemd-journal\n"
+	if got := renderSysusersConf(&cfg); got != expected {
+		t.Errorf("Sysusers content mismatch.\nExpected:\n%s\nGot:\n%s", expected, got)
+	}
+}
+
+// TestWithSysusersValidation tests rejection of unsafe account settings
+func TestWithSysusersValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		acct AccountConfig
+	}{
+		{"negative uid", AccountConfig{UID: -1}},
+		{"quoted gecos", AccountConfig{GECOS: `say "hi"`}},
+		{"bad group", AccountConfig{Groups: []string{"two words"}}},
+		{"relative home", AccountConfig{Home: "var/lib/app"}},
+		{"home with space", AccountConfig{Home: "/var/lib/my app"}},
+		{"quoted home", AccountConfig{Home: `/var/lib/"app"`}},
+		{"relative shell", AccountConfig{Shell: "nologin"}},
+		{"shell with newline", AccountConfig{Shell: "/bin/sh\nu root 0"}},
+		{"quoted shell", AccountConfig{Shell: "/bin/'sh'"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ServiceConfig{}
+			WithSysusers(tt.acct)(&cfg)
+			if err := cfg.Validate(); err == nil {
+				t.Error("Expected validation error, got nil")
+			}
+			if cfg.UseSysusers {
+				t.Error("Expected UseSysusers to remain false")
+			}
+		})
+	}
+}
+
+// TestCheckExistingUser tests detection of accounts that exist with different settings
+func TestCheckExistingUser(t *testing.T) {
+	u := &user.User{Username: "svc", Uid: "812", Gid: "813", HomeDir: "/var/lib/svc"}
+
+	tests := []struct {
+		name    string
+		gid     string
+		acct    AccountConfig
+		wantErr bool
+	}{
+		{"matching", "813", AccountConfig{UID: 812, Home: "/var/lib/svc"}, false},
+		{"dynamic ids", "813", AccountConfig{}, false},
+		{"primary group mismatch", "900", AccountConfig{}, true},
+		{"uid mismatch", "813", AccountConfig{UID: 700}, true},
+		{"home mismatch", "813", AccountConfig{Home: "/srv/svc"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkExistingUser(u, tt.gid, &tt.acct)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkExistingUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrAccountMismatch) {
+				t.Errorf("Expected ErrAccountMismatch, got %v", err)
+			}
+		})
+	}
+}
+
+// TestEnsureGroupExisting tests group lookups for groups that already exist
+func TestEnsureGroupExisting(t *testing.T) {
+	root, err := user.LookupGroupId("0")
+	if err != nil {
+		t.Skipf("No group with GID 0: %v", err)
+	}
+
+	gid, err := ensureGroup(&ServiceConfig{}, root.Name, 0)
+	if err != nil {
+		t.Fatalf("ensureGroup failed for existing group: %v", err)
+	}
+	if gid != "0" {
+		t.Errorf("Expected GID 0, got %s", gid)
+	}
+
+	if _, err := ensureGroup(&ServiceConfig{}, root.Name, 4242); !errors.Is(err, ErrAccountMismatch) {
+		t.Errorf("Expected ErrAccountMismatch for GID mismatch, got %v", err)
+	}
+}
+
+// TestEnsureHome tests home directory creation and mode
+func TestEnsureHome(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("Cannot determine current user: %v", err)
+	}
+
+	home := filepath.Join(t.TempDir(), "svc")
+	if err := ensureHome(home, 0o700, current); err != nil {
+		t.Fatalf("ensureHome failed: %v", err)
+	}
+
+	info, err := os.Stat(home)
+	if err != nil {
+		t.Fatalf("Home directory not created: %v", err)
+	}
+	if info.Mode().Perm() != 0o700 {
+		t.Errorf("Expected mode 0700, got %o", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,417 @@
+package systemd
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	systemdService        = "org.freedesktop.systemd1"
+	systemdPath           = dbusObjectPath("/org/freedesktop/systemd1")
+	systemdManagerIface   = "org.freedesktop.systemd1.Manager"
+	systemdUnitIface      = "org.freedesktop.systemd1.Unit"
+	systemdServiceIface   = "org.freedesktop.systemd1.Service"
+	systemdJobIface       = "org.freedesktop.systemd1.Job"
+	dbusPropertiesIface   = "org.freedesktop.DBus.Properties"
+	systemdJobRemovedRule = "type='signal',sender='org.freedesktop.systemd1',interface='org.freedesktop.systemd1.Manager',member='JobRemoved'"
+)
+
+// dbusCallTimeout bounds each D-Bus method call. Jobs are awaited without a
+// timeout since systemd enforces the unit's own timeouts; their state is polled
+// every dbusJobPollInterval in case the JobRemoved signal is lost.
+const dbusCallTimeout = 30 * time.Second
+
+// dbusJobPollInterval is the interval at which a queued job is checked while
+// waiting for its JobRemoved signal.
+const dbusJobPollInterval = 5 * time.Second
+
+// backend performs service manager operations for the Manager.
+type backend interface {
+	reload() error
+	enable(unit string) error
+	disable(unit string) error
+	job(op, unit string) (JobResult, error)
+	properties(unit string, names ...string) (map[string]string, error)
+	notify(ctx context.Context, unit string, interval time.Duration) (<-chan struct{}, error)
+	close() error
+}
+
+// WithDBus makes the Manager talk to systemd over D-Bus (org.freedesktop.systemd1)
+// instead of running systemctl. The system bus is used, or the session bus with
+// WithUserScope, unless WithBusAddress sets another address.
+func WithDBus() Option {
+	return func(m *Manager) { m.useDBus = true }
+}
+
+// WithBusAddress connects the D-Bus backend to the bus at addr, e.g.
+// "unix:path=/run/dbus/system_bus_socket". It implies WithDBus.
+func WithBusAddress(addr string) Option {
+	return func(m *Manager) {
+		m.useDBus = true
+		m.busAddress = addr
+	}
+}
+
+// openBackend returns the backend selected by the Manager options.
+func (m *Manager) openBackend() (backend, error) {
+	if !m.useDBus {
+		return systemctlBackend{m}, nil
+	}
+	addr := m.busAddress
+	if addr == "" {
+		addr = systemBusAddress()
+		if m.cfg.userScope {
+			addr = sessionBusAddress()
+		}
+	}
+	conn, err := dialDBus(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSystemdUnavailable, err)
+	}
+	b, err := newDBusBackend(conn)
+	if err != nil {
+		_ = conn.Close()
+		var dbusErr *DBusError
+		if errors.As(err, &dbusErr) && dbusErr.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
+			err = fmt.Errorf("%w: %w", ErrSystemdUnavailable, err)
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// systemctlBackend runs systemctl for each operation.
+type systemctlBackend struct {
+	m *Manager
+}
+
+func (b systemctlBackend) reload() error             { return b.m.systemctl("daemon-reload") }
+func (b systemctlBackend) enable(unit string) error  { return b.m.systemctl("enable", unit) }
+func (b systemctlBackend) disable(unit string) error { return b.m.systemctl("disable", unit) }
+func (b systemctlBackend) close() error              { return nil }
+
+// job runs systemctl start, stop or restart, which waits for the job to finish.
+func (b systemctlBackend) job(op, unit string) (JobResult, error) {
+	if err := b.m.systemctl(op, unit); err != nil {
+		return systemctlJobResult(err), err
+	}
+	return JobDone, nil
+}
+
+// properties reads unit properties with systemctl show.
+func (b systemctlBackend) properties(unit string, names ...string) (map[string]string, error) {
+	args := []string{"show", "--property", strings.Join(names, ","), unit}
+	if b.m.cfg.userScope {
+		args = append([]string{"--user"}, args...)
+	}
+	out, _, err := runCommand("systemctl", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(names))
+	for _, line := range strings.Split(string(out), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok && slices.Contains(names, key) {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// notify signals on every poll interval, since systemctl cannot wait for changes.
+func (b systemctlBackend) notify(ctx context.Context, unit string, interval time.Duration) (<-chan struct{}, error) {
+	ch := make(chan struct{})
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				select {
+				case ch <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// dbusBackend calls the systemd manager over D-Bus.
+type dbusBackend struct {
+	conn         *dbusConn
+	pollInterval time.Duration // Interval of job state checks (dbusJobPollInterval)
+}
+
+// newDBusBackend subscribes to job completion signals on conn.
+func newDBusBackend(conn *dbusConn) (*dbusBackend, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbusCallTimeout)
+	defer cancel()
+	if err := conn.addMatch(ctx, systemdJobRemovedRule); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to systemd jobs: %w", err)
+	}
+	// systemd only emits job signals to subscribed clients
+	if _, err := conn.call(ctx, systemdService, systemdPath, systemdManagerIface, "Subscribe", ""); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to systemd jobs: %w", err)
+	}
+	return &dbusBackend{conn: conn, pollInterval: dbusJobPollInterval}, nil
+}
+
+// call invokes a systemd manager method.
+func (b *dbusBackend) call(member, sig string, args ...interface{}) ([]interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbusCallTimeout)
+	defer cancel()
+	return b.conn.call(ctx, systemdService, systemdPath, systemdManagerIface, member, sig, args...)
+}
+
+func (b *dbusBackend) reload() error {
+	if _, err := b.call("Reload", ""); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", err)
+	}
+	return nil
+}
+
+func (b *dbusBackend) enable(unit string) error {
+	if _, err := b.call("EnableUnitFiles", "asbb", []string{unit}, false, false); err != nil {
+		return fmt.Errorf("failed to enable %s: %w", unit, err)
+	}
+	// Like systemctl enable, reload so the new symlinks take effect
+	return b.reload()
+}
+
+func (b *dbusBackend) disable(unit string) error {
+	if _, err := b.call("DisableUnitFiles", "asb", []string{unit}, false); err != nil {
+		return fmt.Errorf("failed to disable %s: %w", unit, err)
+	}
+	return b.reload()
+}
+
+// dbusJobMethods maps job operations to systemd manager methods.
+var dbusJobMethods = map[string]string{
+	"start":   "StartUnit",
+	"stop":    "StopUnit",
+	"restart": "RestartUnit",
+}
+
+// job queues a start, stop or restart job for unit and waits until systemd
+// removes it, returning the result from the JobRemoved signal.
+func (b *dbusBackend) job(op, unit string) (JobResult, error) {
+	method, ok := dbusJobMethods[op]
+	if !ok {
+		return "", fmt.Errorf("unsupported job operation %q", op)
+	}
+
+	// Subscribe before queueing so a fast job's signal is not missed
+	signals, lost, unsubscribe := b.conn.subscribe()
+	defer unsubscribe()
+
+	reply, err := b.call(method, "ss", unit, "replace")
+	if err != nil {
+		return "", fmt.Errorf("failed to %s %s: %w", op, unit, err)
+	}
+	job, err := replyValue[dbusObjectPath](reply)
+	if err == nil && job == "" {
+		err = errors.New("empty job path")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to %s %s: %w", op, unit, err)
+	}
+
+	ticker := time.NewTicker(b.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case sig := <-signals:
+			if sig.Member != "JobRemoved" || len(sig.Body) < 4 || sig.Body[1] != job {
+				continue
+			}
+			result, ok := sig.Body[3].(string)
+			if !ok || result == "" {
+				return "", fmt.Errorf("failed to %s %s: unexpected job result %v", op, unit, sig.Body[3])
+			}
+			return JobResult(result), nil
+		case <-lost:
+		case <-ticker.C:
+		case <-b.conn.done:
+			return "", fmt.Errorf("failed to %s %s: D-Bus connection closed: %w", op, unit, b.conn.err)
+		}
+
+		// The signal may have been dropped; check whether the job still exists
+		if result, err := b.removedJobResult(job, op, unit); err != nil || result != "" {
+			return result, err
+		}
+	}
+}
+
+// removedJobResult returns an empty result while job is queued. Once systemd
+// removed it, the result is derived from the unit state since the JobRemoved
+// signal carrying it was missed.
+func (b *dbusBackend) removedJobResult(job dbusObjectPath, op, unit string) (JobResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbusCallTimeout)
+	defer cancel()
+	_, err := b.conn.call(ctx, systemdService, job, dbusPropertiesIface, "Get", "ss", systemdJobIface, "State")
+	if err == nil {
+		return "", nil
+	}
+	var dbusErr *DBusError
+	if !errors.As(err, &dbusErr) || !(strings.HasSuffix(dbusErr.Name, ".UnknownObject") || strings.HasSuffix(dbusErr.Name, ".NoSuchJob")) {
+		return "", fmt.Errorf("failed to check %s job of %s: %w", op, unit, err)
+	}
+
+	props, err := b.properties(unit, "ActiveState")
+	if err != nil {
+		return "", err
+	}
+	if op != "stop" && props["ActiveState"] == "failed" {
+		return JobFailed, nil
+	}
+	return JobDone, nil
+}
+
+// properties reads unit properties, looking each up on the Unit and then on
+// the Service interface. Values are formatted like systemctl show.
+func (b *dbusBackend) properties(unit string, names ...string) (map[string]string, error) {
+	path, err := b.unitPath(unit)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbusCallTimeout)
+	defer cancel()
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		found := false
+		for _, iface := range []string{systemdUnitIface, systemdServiceIface} {
+			reply, err := b.conn.call(ctx, systemdService, path, dbusPropertiesIface, "Get", "ss", iface, name)
+			var dbusErr *DBusError
+			if errors.As(err, &dbusErr) && strings.HasSuffix(dbusErr.Name, ".UnknownProperty") {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s of %s: %w", name, unit, err)
+			}
+			v, err := replyValue[dbusVariant](reply)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s of %s: %w", name, unit, err)
+			}
+			values[name] = formatProperty(v.Value)
+			found = true
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("unit %s has no property %s", unit, name)
+		}
+	}
+	return values, nil
+}
+
+// unitPath loads unit and returns its object path.
+func (b *dbusBackend) unitPath(unit string) (dbusObjectPath, error) {
+	reply, err := b.call("LoadUnit", "s", unit)
+	if err != nil {
+		return "", fmt.Errorf("failed to load %s: %w", unit, err)
+	}
+	path, err := replyValue[dbusObjectPath](reply)
+	if err != nil {
+		return "", fmt.Errorf("failed to load %s: %w", unit, err)
+	}
+	return path, nil
+}
+
+// notify signals whenever systemd reports changed properties of unit. The
+// channel is closed when ctx is done or the connection is lost.
+func (b *dbusBackend) notify(ctx context.Context, unit string, _ time.Duration) (<-chan struct{}, error) {
+	path, err := b.unitPath(unit)
+	if err != nil {
+		return nil, err
+	}
+
+	signals, lost, unsubscribe := b.conn.subscribe()
+	callCtx, cancel := context.WithTimeout(ctx, dbusCallTimeout)
+	defer cancel()
+	rule := fmt.Sprintf("type='signal',sender='%s',path='%s',interface='%s',member='PropertiesChanged'",
+		systemdService, path, dbusPropertiesIface)
+	if err := b.conn.addMatch(callCtx, rule); err != nil {
+		unsubscribe()
+		return nil, fmt.Errorf("failed to watch %s: %w", unit, err)
+	}
+
+	ch := make(chan struct{})
+	go func() {
+		defer close(ch)
+		defer unsubscribe()
+		for {
+			select {
+			case sig := <-signals:
+				if sig.Member != "PropertiesChanged" || sig.Path != path {
+					continue
+				}
+			case <-lost:
+				// Dropped signals may include changes; the receiver re-reads the state
+			case <-ctx.Done():
+				return
+			case <-b.conn.done:
+				return
+			}
+			select {
+			case ch <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (b *dbusBackend) close() error {
+	return b.conn.Close()
+}
+
+// formatProperty formats a property value the way systemctl show prints it.
+func formatProperty(v interface{}) string {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return "yes"
+		}
+		return "no"
+	case []interface{}:
+		// Byte arrays such as InvocationID are printed in hex
+		if b, ok := byteArray(v); ok {
+			return hex.EncodeToString(b)
+		}
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, formatProperty(item))
+		}
+		return strings.Join(items, " ")
+	case dbusVariant:
+		return formatProperty(v.Value)
+	}
+	return fmt.Sprint(v)
+}
+
+// byteArray returns the bytes of a non-empty decoded byte array ('ay').
+func byteArray(v []interface{}) ([]byte, bool) {
+	if len(v) == 0 {
+		return nil, false
+	}
+	b := make([]byte, len(v))
+	for i, item := range v {
+		c, ok := item.(byte)
+		if !ok {
+			return nil, false
+		}
+		b[i] = c
+	}
+	return b, true
+}
@@ -0,0 +1,323 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeUnitPath is the object path the fake systemd reports for every unit.
+const fakeUnitPath = dbusObjectPath("/org/freedesktop/systemd1/unit/app_2eservice")
+
+// fakeUnitProperties lists the fake properties served on the Unit interface;
+// the others are served on the Service interface.
+var fakeUnitProperties = map[string]bool{"ActiveState": true, "SubState": true, "InvocationID": true, "Result": true}
+
+// fakeSystemd serves a minimal org.freedesktop.systemd1 on a test bus.
+type fakeSystemd struct {
+	conn *dbusConn
+
+	mu        sync.Mutex
+	calls     []string
+	jobResult string                   // Result reported in JobRemoved, "done" unless set
+	noSignal  bool                     // Finish jobs without emitting JobRemoved
+	replies   map[string][]interface{} // Signature and values replied instead, by method
+	props     map[string]dbusVariant   // Properties of the unit
+}
+
+// startFakeSystemd claims the systemd bus name on addr and serves manager calls.
+func startFakeSystemd(t *testing.T, addr string) *fakeSystemd {
+	t.Helper()
+	conn, err := dialDBus(addr)
+	if err != nil {
+		t.Fatalf("dialDBus failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	f := &fakeSystemd{conn: conn, jobResult: "done", props: map[string]dbusVariant{
+		"ActiveState":    {Sig: "s", Value: "active"},
+		"SubState":       {Sig: "s", Value: "running"},
+		"InvocationID":   {Sig: "ay", Value: []interface{}{byte(0xa)}},
+		"Result":         {Sig: "s", Value: "exit-code"},
+		"NRestarts":      {Sig: "u", Value: uint32(3)},
+		"MainPID":        {Sig: "u", Value: uint32(10)},
+		"ExecMainStatus": {Sig: "i", Value: int32(2)},
+	}}
+	conn.setHandler(f.handle)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := conn.call(ctx, dbusService, dbusPath, dbusInterface, "RequestName", "su", systemdService, uint32(0)); err != nil {
+		t.Fatalf("RequestName failed: %v", err)
+	}
+	return f
+}
+
+func (f *fakeSystemd) handle(msg *dbusMessage) {
+	f.mu.Lock()
+	call := []string{msg.Member}
+	for _, arg := range msg.Body {
+		call = append(call, formatProperty(arg))
+	}
+	f.calls = append(f.calls, strings.Join(call, " "))
+	result := f.jobResult
+	noSignal := f.noSignal
+	override, overridden := f.replies[msg.Member]
+	var prop dbusVariant
+	var found bool
+	if msg.Member == "Get" && strings.HasPrefix(string(msg.Path), "/org/freedesktop/systemd1/job/") {
+		// Jobs finish immediately, so their objects no longer exist
+		f.mu.Unlock()
+		_ = f.conn.reply(msg, &DBusError{Name: "org.freedesktop.DBus.Error.UnknownObject"})
+		return
+	}
+	if msg.Member == "Get" {
+		name := msg.Body[1].(string)
+		prop, found = f.props[name]
+		found = found && (msg.Body[0] == systemdUnitIface) == fakeUnitProperties[name]
+	}
+	f.mu.Unlock()
+
+	if overridden {
+		_ = f.conn.reply(msg, nil, override...)
+		return
+	}
+	switch msg.Member {
+	case "Subscribe", "Reload":
+		_ = f.conn.reply(msg, nil)
+	case "EnableUnitFiles":
+		_ = f.conn.reply(msg, nil, "ba(sss)", true, []interface{}{})
+	case "DisableUnitFiles":
+		_ = f.conn.reply(msg, nil, "a(sss)", []interface{}{})
+	case "StartUnit", "StopUnit", "RestartUnit":
+		job := dbusObjectPath("/org/freedesktop/systemd1/job/7")
+		_ = f.conn.reply(msg, nil, "o", job)
+		if noSignal {
+			return
+		}
+		_ = f.conn.emit(systemdPath, systemdManagerIface, "JobRemoved", "uoss", uint32(7), job, msg.Body[0], result)
+	case "LoadUnit":
+		_ = f.conn.reply(msg, nil, "o", fakeUnitPath)
+	case "Get":
+		if !found {
+			_ = f.conn.reply(msg, &DBusError{Name: "org.freedesktop.DBus.Error.UnknownProperty"})
+			return
+		}
+		_ = f.conn.reply(msg, nil, "v", prop)
+	default:
+		_ = f.conn.reply(msg, &DBusError{Name: "org.freedesktop.DBus.Error.UnknownMethod", Message: msg.Member})
+	}
+}
+
+// setProperties changes unit properties and emits PropertiesChanged.
+func (f *fakeSystemd) setProperties(props map[string]dbusVariant) error {
+	f.mu.Lock()
+	for name, v := range props {
+		f.props[name] = v
+	}
+	f.mu.Unlock()
+	return f.conn.emit(fakeUnitPath, dbusPropertiesIface, "PropertiesChanged", "sa{sv}as",
+		systemdUnitIface, []interface{}{}, []interface{}{})
+}
+
+func (f *fakeSystemd) callLog() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return strings.Join(f.calls, "\n")
+}
+
+// TestDBusBackend tests manager operations against a fake systemd on a test bus
+func TestDBusBackend(t *testing.T) {
+	addr := startDBusDaemon(t)
+	fake := startFakeSystemd(t, addr)
+
+	cfg := &ServiceConfig{ServiceName: "app.service", SystemdFile: "/tmp/app.service"}
+	m := NewManager(cfg, WithBusAddress(addr))
+	b, err := m.openBackend()
+	if err != nil {
+		t.Fatalf("openBackend failed: %v", err)
+	}
+	defer b.close()
+
+	if err := b.reload(); err != nil {
+		t.Errorf("reload failed: %v", err)
+	}
+	if err := b.enable("app.service"); err != nil {
+		t.Errorf("enable failed: %v", err)
+	}
+	if result, err := b.job("start", "app.service"); err != nil || result != JobDone {
+		t.Errorf("Expected start job done, got %q (%v)", result, err)
+	}
+	if result, err := b.job("stop", "app.service"); err != nil || result != JobDone {
+		t.Errorf("Expected stop job done, got %q (%v)", result, err)
+	}
+	if err := b.disable("app.service"); err != nil {
+		t.Errorf("disable failed: %v", err)
+	}
+
+	props, err := b.properties("app.service", "ActiveState", "NRestarts")
+	if err != nil || props["ActiveState"] != "active" || props["NRestarts"] != "3" {
+		t.Errorf("Expected ActiveState active and NRestarts 3 from the Service interface, got %v (%v)", props, err)
+	}
+	if _, err := b.properties("app.service", "Bogus"); err == nil {
+		t.Error("Expected error for unknown property")
+	}
+
+	calls := fake.callLog()
+	for _, want := range []string{"Subscribe", "Reload", "EnableUnitFiles app.service no no", "StartUnit app.service",
+		"StopUnit app.service", "DisableUnitFiles app.service", "LoadUnit app.service"} {
+		if !strings.Contains(calls, want) {
+			t.Errorf("Expected %q in calls:\n%s", want, calls)
+		}
+	}
+}
+
+// TestDBusBackendJobFailure tests that the JobRemoved result is returned
+func TestDBusBackendJobFailure(t *testing.T) {
+	addr := startDBusDaemon(t)
+	fake := startFakeSystemd(t, addr)
+	fake.mu.Lock()
+	fake.jobResult = "dependency"
+	fake.mu.Unlock()
+
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"}, WithBusAddress(addr))
+	b, err := m.openBackend()
+	if err != nil {
+		t.Fatalf("openBackend failed: %v", err)
+	}
+	defer b.close()
+
+	if result, err := b.job("restart", "app.service"); err != nil || result != JobDependency {
+		t.Errorf("Expected restart job dependency, got %q (%v)", result, err)
+	}
+}
+
+// TestDBusBackendMalformedReply tests that empty or wrongly typed replies are errors
+func TestDBusBackendMalformedReply(t *testing.T) {
+	addr := startDBusDaemon(t)
+	fake := startFakeSystemd(t, addr)
+
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"}, WithBusAddress(addr))
+	b, err := m.openBackend()
+	if err != nil {
+		t.Fatalf("openBackend failed: %v", err)
+	}
+	defer b.close()
+
+	fake.mu.Lock()
+	fake.replies = map[string][]interface{}{"StartUnit": nil, "LoadUnit": {"s", "app.service"}}
+	fake.mu.Unlock()
+	if _, err := b.job("start", "app.service"); err == nil {
+		t.Error("Expected error for an empty StartUnit reply")
+	}
+	if _, err := b.properties("app.service", "ActiveState"); err == nil {
+		t.Error("Expected error for a LoadUnit reply that is not an object path")
+	}
+
+	fake.mu.Lock()
+	fake.replies = map[string][]interface{}{"Get": nil}
+	fake.mu.Unlock()
+	if _, err := b.properties("app.service", "ActiveState"); err == nil {
+		t.Error("Expected error for an empty Get reply")
+	}
+}
+
+// TestDBusBackendLostJobSignal tests that a job whose JobRemoved signal is lost
+// is resolved from the unit state
+func TestDBusBackendLostJobSignal(t *testing.T) {
+	addr := startDBusDaemon(t)
+	fake := startFakeSystemd(t, addr)
+	fake.mu.Lock()
+	fake.noSignal = true
+	fake.props["ActiveState"] = dbusVariant{Sig: "s", Value: "failed"}
+	fake.mu.Unlock()
+
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"}, WithBusAddress(addr))
+	b, err := m.openBackend()
+	if err != nil {
+		t.Fatalf("openBackend failed: %v", err)
+	}
+	defer b.close()
+	b.(*dbusBackend).pollInterval = 10 * time.Millisecond
+
+	if result, err := b.job("start", "app.service"); err != nil || result != JobFailed {
+		t.Errorf("Expected start job failed, got %q (%v)", result, err)
+	}
+	if result, err := b.job("stop", "app.service"); err != nil || result != JobDone {
+		t.Errorf("Expected stop job done, got %q (%v)", result, err)
+	}
+}
+
+// TestDBusSubscriberLost tests that a subscriber is notified of dropped signals
+func TestDBusSubscriberLost(t *testing.T) {
+	addr := startDBusDaemon(t)
+	fake := startFakeSystemd(t, addr)
+
+	conn, err := dialDBus(addr)
+	if err != nil {
+		t.Fatalf("dialDBus failed: %v", err)
+	}
+	defer conn.Close()
+	signals, lost, unsubscribe := conn.subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := conn.addMatch(ctx, "type='signal',interface='org.example.Test'"); err != nil {
+		t.Fatalf("addMatch failed: %v", err)
+	}
+	for i := 0; i < dbusSignalBuffer+10; i++ {
+		if err := fake.conn.emit("/org/example", "org.example.Test", "Ping", ""); err != nil {
+			t.Fatalf("emit failed: %v", err)
+		}
+	}
+
+	select {
+	case <-lost:
+	case <-ctx.Done():
+		t.Fatal("Expected lost notification")
+	}
+	if len(signals) != dbusSignalBuffer {
+		t.Errorf("Expected %d queued signals, got %d", dbusSignalBuffer, len(signals))
+	}
+}
+
+// TestDBusBackendNoSystemd tests the error when systemd is not on the bus
+func TestDBusBackendNoSystemd(t *testing.T) {
+	addr := startDBusDaemon(t)
+
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"}, WithBusAddress(addr))
+	_, err := m.openBackend()
+	var dbusErr *DBusError
+	if !errors.As(err, &dbusErr) || !errors.Is(err, ErrSystemdUnavailable) {
+		t.Errorf("Expected DBusError matching ErrSystemdUnavailable, got %v", err)
+	}
+}
+
+// TestSystemctlBackend tests that the default backend runs systemctl
+func TestSystemctlBackend(t *testing.T) {
+	dir := fakeCommands(t, map[string]string{"systemctl": `[ "$1" = show ] && printf 'ActiveState=active\nSubState=running\n'; exit 0`})
+
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"})
+	b, err := m.openBackend()
+	if err != nil {
+		t.Fatalf("openBackend failed: %v", err)
+	}
+	if result, err := b.job("start", "app.service"); err != nil || result != JobDone {
+		t.Errorf("Expected start job done, got %q (%v)", result, err)
+	}
+	props, err := b.properties("app.service", "ActiveState", "SubState")
+	if err != nil || props["ActiveState"] != "active" || props["SubState"] != "running" {
+		t.Errorf("Expected active and running, got %v (%v)", props, err)
+	}
+
+	calls := fakeCalls(t, dir)
+	for _, want := range []string{"systemctl start app.service", "systemctl show --property ActiveState,SubState app.service"} {
+		if !strings.Contains(calls, want) {
+			t.Errorf("Expected %q in calls:\n%s", want, calls)
+		}
+	}
+}
@@ -0,0 +1,950 @@
+package systemd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// D-Bus message types.
+const (
+	dbusMethodCall   byte = 1
+	dbusMethodReturn byte = 2
+	dbusError        byte = 3
+	dbusSignal       byte = 4
+)
+
+// dbusNoReplyExpected is the message flag telling the peer not to reply.
+const dbusNoReplyExpected byte = 0x1
+
+// D-Bus header field codes.
+const (
+	dbusFieldPath        byte = 1
+	dbusFieldInterface   byte = 2
+	dbusFieldMember      byte = 3
+	dbusFieldErrorName   byte = 4
+	dbusFieldReplySerial byte = 5
+	dbusFieldDestination byte = 6
+	dbusFieldSender      byte = 7
+	dbusFieldSignature   byte = 8
+)
+
+// dbusMaxMessage is the maximum message size allowed by the specification.
+const dbusMaxMessage = 128 << 20
+
+// dbusMaxDepth is the maximum nesting of containers (arrays, structs and
+// variants) allowed by the specification.
+const dbusMaxDepth = 64
+
+// dbusSignalBuffer is the number of signals queued per subscriber before
+// further signals are dropped and the subscriber is told to resync.
+const dbusSignalBuffer = 256
+
+const (
+	dbusService   = "org.freedesktop.DBus"
+	dbusPath      = "/org/freedesktop/DBus"
+	dbusInterface = "org.freedesktop.DBus"
+
+	// DefaultSystemBusAddress is the system bus used when DBUS_SYSTEM_BUS_ADDRESS is unset.
+	DefaultSystemBusAddress = "unix:path=/run/dbus/system_bus_socket"
+)
+
+// dbusObjectPath is a D-Bus object path ('o').
+type dbusObjectPath string
+
+// dbusSignature is a D-Bus type signature ('g').
+type dbusSignature string
+
+// dbusVariant is a value with its own signature ('v').
+type dbusVariant struct {
+	Sig   string
+	Value interface{}
+}
+
+// DBusError is an error reply received from a D-Bus peer.
+type DBusError struct {
+	Name    string // Error name, e.g. "org.freedesktop.systemd1.NoSuchUnit"
+	Message string // Human readable description
+}
+
+// Error implements the error interface.
+func (e *DBusError) Error() string {
+	if e.Message == "" {
+		return e.Name
+	}
+	return e.Name + ": " + e.Message
+}
+
+// dbusMessage is a decoded D-Bus message.
+type dbusMessage struct {
+	Type        byte
+	Flags       byte
+	Serial      uint32
+	Path        dbusObjectPath
+	Interface   string
+	Member      string
+	ErrorName   string
+	ReplySerial uint32
+	Destination string
+	Sender      string
+	Signature   string
+	Body        []interface{}
+}
+
+// dbusEncoder marshals values in little-endian D-Bus wire format. Offsets are
+// relative to the start of the message, which determines alignment.
+type dbusEncoder struct {
+	buf bytes.Buffer
+}
+
+// align pads the buffer with zero bytes to a multiple of n.
+func (e *dbusEncoder) align(n int) {
+	for e.buf.Len()%n != 0 {
+		e.buf.WriteByte(0)
+	}
+}
+
+func (e *dbusEncoder) uint32(v uint32) {
+	e.align(4)
+	_ = binary.Write(&e.buf, binary.LittleEndian, v)
+}
+
+// encode marshals the values following the signature.
+func (e *dbusEncoder) encode(sig string, values []interface{}) error {
+	types, err := splitSignature(sig)
+	if err != nil {
+		return err
+	}
+	if len(types) != len(values) {
+		return fmt.Errorf("signature %q expects %d values, got %d", sig, len(types), len(values))
+	}
+	for i, t := range types {
+		if err := e.encodeValue(t, values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeValue marshals a single value of a complete type.
+func (e *dbusEncoder) encodeValue(t string, v interface{}) error {
+	bad := func() error { return fmt.Errorf("cannot encode %T as %q", v, t) }
+
+	switch t[0] {
+	case 'y':
+		b, ok := v.(byte)
+		if !ok {
+			return bad()
+		}
+		e.buf.WriteByte(b)
+	case 'b':
+		b, ok := v.(bool)
+		if !ok {
+			return bad()
+		}
+		var u uint32
+		if b {
+			u = 1
+		}
+		e.uint32(u)
+	case 'n', 'q':
+		var u uint16
+		switch n := v.(type) {
+		case int16:
+			u = uint16(n)
+		case uint16:
+			u = n
+		default:
+			return bad()
+		}
+		e.align(2)
+		_ = binary.Write(&e.buf, binary.LittleEndian, u)
+	case 'i':
+		n, ok := v.(int32)
+		if !ok {
+			return bad()
+		}
+		e.uint32(uint32(n))
+	case 'u', 'h':
+		n, ok := v.(uint32)
+		if !ok {
+			return bad()
+		}
+		e.uint32(n)
+	case 'x', 't', 'd':
+		var u uint64
+		switch n := v.(type) {
+		case int64:
+			u = uint64(n)
+		case uint64:
+			u = n
+		case float64:
+			u = math.Float64bits(n)
+		default:
+			return bad()
+		}
+		e.align(8)
+		_ = binary.Write(&e.buf, binary.LittleEndian, u)
+	case 's', 'o':
+		var s string
+		switch str := v.(type) {
+		case string:
+			s = str
+		case dbusObjectPath:
+			s = string(str)
+		default:
+			return bad()
+		}
+		e.uint32(uint32(len(s)))
+		e.buf.WriteString(s)
+		e.buf.WriteByte(0)
+	case 'g':
+		var s string
+		switch str := v.(type) {
+		case string:
+			s = str
+		case dbusSignature:
+			s = string(str)
+		default:
+			return bad()
+		}
+		e.buf.WriteByte(byte(len(s)))
+		e.buf.WriteString(s)
+		e.buf.WriteByte(0)
+	case 'v':
+		variant, ok := v.(dbusVariant)
+		if !ok {
+			return bad()
+		}
+		if err := e.encodeValue("g", variant.Sig); err != nil {
+			return err
+		}
+		return e.encodeValue(variant.Sig, variant.Value)
+	case 'a':
+		var items []interface{}
+		switch a := v.(type) {
+		case []interface{}:
+			items = a
+		case []string:
+			for _, s := range a {
+				items = append(items, s)
+			}
+		default:
+			return bad()
+		}
+		e.uint32(0)
+		lengthAt := e.buf.Len() - 4
+		elem := t[1:]
+		e.align(dbusAlignment(elem[0]))
+		start := e.buf.Len()
+		for _, item := range items {
+			if err := e.encodeValue(elem, item); err != nil {
+				return err
+			}
+		}
+		binary.LittleEndian.PutUint32(e.buf.Bytes()[lengthAt:], uint32(e.buf.Len()-start))
+	case '(', '{':
+		fields, ok := v.([]interface{})
+		if !ok {
+			return bad()
+		}
+		e.align(8)
+		return e.encode(t[1:len(t)-1], fields)
+	default:
+		return fmt.Errorf("unsupported type %q", t)
+	}
+	return nil
+}
+
+// dbusDecoder unmarshals D-Bus wire format.
+type dbusDecoder struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+var errDBusShort = errors.New("truncated D-Bus message")
+
+func (d *dbusDecoder) align(n int) error {
+	for d.pos%n != 0 {
+		if d.pos >= len(d.data) {
+			return errDBusShort
+		}
+		d.pos++
+	}
+	return nil
+}
+
+func (d *dbusDecoder) read(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, errDBusShort
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *dbusDecoder) uint32() (uint32, error) {
+	if err := d.align(4); err != nil {
+		return 0, err
+	}
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return d.order.Uint32(b), nil
+}
+
+// decode unmarshals the values of a signature nested depth containers deep.
+func (d *dbusDecoder) decode(sig string, depth int) ([]interface{}, error) {
+	types, err := splitSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0, len(types))
+	for _, t := range types {
+		v, err := d.decodeValue(t, depth)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// decodeValue unmarshals a single value of a complete type nested depth
+// containers deep.
+func (d *dbusDecoder) decodeValue(t string, depth int) (interface{}, error) {
+	if t == "" {
+		return nil, errors.New("empty D-Bus type")
+	}
+	switch t[0] {
+	case 'v', 'a', '(', '{':
+		if depth >= dbusMaxDepth {
+			return nil, fmt.Errorf("D-Bus value nested deeper than %d containers", dbusMaxDepth)
+		}
+	}
+	switch t[0] {
+	case 'y':
+		b, err := d.read(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0], nil
+	case 'b':
+		u, err := d.uint32()
+		return u != 0, err
+	case 'n', 'q':
+		if err := d.align(2); err != nil {
+			return nil, err
+		}
+		b, err := d.read(2)
+		if err != nil {
+			return nil, err
+		}
+		if t[0] == 'n' {
+			return int16(d.order.Uint16(b)), nil
+		}
+		return d.order.Uint16(b), nil
+	case 'i':
+		u, err := d.uint32()
+		return int32(u), err
+	case 'u', 'h':
+		return d.uint32()
+	case 'x', 't', 'd':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		b, err := d.read(8)
+		if err != nil {
+			return nil, err
+		}
+		u := d.order.Uint64(b)
+		switch t[0] {
+		case 'x':
+			return int64(u), nil
+		case 'd':
+			return math.Float64frombits(u), nil
+		}
+		return u, nil
+	case 's', 'o':
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.read(int(n) + 1)
+		if err != nil {
+			return nil, err
+		}
+		if t[0] == 'o' {
+			return dbusObjectPath(b[:n]), nil
+		}
+		return string(b[:n]), nil
+	case 'g':
+		n, err := d.read(1)
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.read(int(n[0]) + 1)
+		if err != nil {
+			return nil, err
+		}
+		return dbusSignature(b[:n[0]]), nil
+	case 'v':
+		sig, err := d.decodeValue("g", depth)
+		if err != nil {
+			return nil, err
+		}
+		s := string(sig.(dbusSignature))
+		types, err := splitSignature(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(types) != 1 {
+			return nil, fmt.Errorf("variant signature %q is not a single complete type", s)
+		}
+		v, err := d.decodeValue(s, depth+1)
+		return dbusVariant{Sig: s, Value: v}, err
+	case 'a':
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		elem := t[1:]
+		if err := d.align(dbusAlignment(elem[0])); err != nil {
+			return nil, err
+		}
+		end := d.pos + int(n)
+		if end > len(d.data) {
+			return nil, errDBusShort
+		}
+		items := []interface{}{}
+		for d.pos < end {
+			item, err := d.decodeValue(elem, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case '(', '{':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		return d.decode(t[1:len(t)-1], depth+1)
+	}
+	return nil, fmt.Errorf("unsupported type %q", t)
+}
+
+// dbusAlignment returns the alignment of a type code.
+func dbusAlignment(code byte) int {
+	switch code {
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 'h', 's', 'o', 'a':
+		return 4
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+	return 1
+}
+
+// splitSignature splits a signature into its complete types.
+func splitSignature(sig string) ([]string, error) {
+	var types []string
+	for len(sig) > 0 {
+		n, err := completeTypeLen(sig)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, sig[:n])
+		sig = sig[n:]
+	}
+	return types, nil
+}
+
+// completeTypeLen returns the length of the complete type at the start of sig.
+func completeTypeLen(sig string) (int, error) {
+	if sig == "" {
+		return 0, errors.New("incomplete D-Bus signature")
+	}
+	switch sig[0] {
+	case 'y', 'b', 'n', 'q', 'i', 'u', 'x', 't', 'd', 's', 'o', 'g', 'v', 'h':
+		return 1, nil
+	case 'a':
+		n, err := completeTypeLen(sig[1:])
+		return n + 1, err
+	case '(', '{':
+		closing := byte(')')
+		if sig[0] == '{' {
+			closing = '}'
+		}
+		i := 1
+		for i < len(sig) && sig[i] != closing {
+			n, err := completeTypeLen(sig[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+		if i >= len(sig) || i == 1 {
+			return 0, fmt.Errorf("invalid D-Bus signature %q", sig)
+		}
+		return i + 1, nil
+	}
+	return 0, fmt.Errorf("invalid D-Bus signature %q", sig)
+}
+
+// marshal serializes the message with the given serial.
+func (msg *dbusMessage) marshal(serial uint32) ([]byte, error) {
+	var body dbusEncoder
+	if err := body.encode(msg.Signature, msg.Body); err != nil {
+		return nil, err
+	}
+
+	var fields []interface{}
+	field := func(code byte, sig string, v interface{}) {
+		fields = append(fields, []interface{}{code, dbusVariant{Sig: sig, Value: v}})
+	}
+	if msg.Path != "" {
+		field(dbusFieldPath, "o", msg.Path)
+	}
+	if msg.Interface != "" {
+		field(dbusFieldInterface, "s", msg.Interface)
+	}
+	if msg.Member != "" {
+		field(dbusFieldMember, "s", msg.Member)
+	}
+	if msg.ErrorName != "" {
+		field(dbusFieldErrorName, "s", msg.ErrorName)
+	}
+	if msg.ReplySerial != 0 {
+		field(dbusFieldReplySerial, "u", msg.ReplySerial)
+	}
+	if msg.Destination != "" {
+		field(dbusFieldDestination, "s", msg.Destination)
+	}
+	if msg.Signature != "" {
+		field(dbusFieldSignature, "g", dbusSignature(msg.Signature))
+	}
+
+	var e dbusEncoder
+	e.buf.Write([]byte{'l', msg.Type, msg.Flags, 1})
+	e.uint32(uint32(body.buf.Len()))
+	e.uint32(serial)
+	if err := e.encodeValue("a(yv)", fields); err != nil {
+		return nil, err
+	}
+	e.align(8)
+	e.buf.Write(body.buf.Bytes())
+	return e.buf.Bytes(), nil
+}
+
+// readDBusMessage reads and decodes one message from r.
+func readDBusMessage(r io.Reader) (*dbusMessage, error) {
+	head := make([]byte, 16)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+
+	var order binary.ByteOrder
+	switch head[0] {
+	case 'l':
+		order = binary.LittleEndian
+	case 'B':
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("invalid D-Bus endianness %q", head[0])
+	}
+	bodyLen := order.Uint32(head[4:8])
+	fieldsLen := order.Uint32(head[12:16])
+	headerLen := 16 + int(fieldsLen)
+	headerLen += (8 - headerLen%8) % 8
+	if int64(headerLen)+int64(bodyLen) > dbusMaxMessage {
+		return nil, errors.New("D-Bus message too large")
+	}
+
+	data := make([]byte, headerLen+int(bodyLen))
+	copy(data, head)
+	if _, err := io.ReadFull(r, data[16:]); err != nil {
+		return nil, err
+	}
+
+	msg := &dbusMessage{Type: head[1], Flags: head[2], Serial: order.Uint32(head[8:12])}
+	d := &dbusDecoder{data: data[:16+fieldsLen], pos: 12, order: order}
+	fields, err := d.decodeValue("a(yv)", 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields.([]interface{}) {
+		pair := f.([]interface{})
+		v := pair[1].(dbusVariant).Value
+		switch pair[0].(byte) {
+		case dbusFieldPath:
+			msg.Path, _ = v.(dbusObjectPath)
+		case dbusFieldInterface:
+			msg.Interface, _ = v.(string)
+		case dbusFieldMember:
+			msg.Member, _ = v.(string)
+		case dbusFieldErrorName:
+			msg.ErrorName, _ = v.(string)
+		case dbusFieldReplySerial:
+			msg.ReplySerial, _ = v.(uint32)
+		case dbusFieldDestination:
+			msg.Destination, _ = v.(string)
+		case dbusFieldSender:
+			msg.Sender, _ = v.(string)
+		case dbusFieldSignature:
+			sig, _ := v.(dbusSignature)
+			msg.Signature = string(sig)
+		}
+	}
+
+	// The body is aligned relative to its own start, which is 8-aligned in the message
+	body := &dbusDecoder{data: data[headerLen:], order: order}
+	if msg.Body, err = body.decode(msg.Signature, 0); err != nil {
+		return nil, fmt.Errorf("invalid D-Bus message body: %w", err)
+	}
+	return msg, nil
+}
+
+// dbusConn is a connection to a message bus.
+type dbusConn struct {
+	conn   net.Conn
+	name   string // Unique name assigned by the bus
+	wmu    sync.Mutex
+	serial uint32
+
+	mu          sync.Mutex
+	pending     map[uint32]chan *dbusMessage
+	subscribers map[chan *dbusMessage]chan struct{} // Signal channels and their lost notifications
+	handler     func(*dbusMessage)                  // Handles incoming method calls (optional)
+	err         error
+	done        chan struct{}
+}
+
+// systemBusAddress returns the address of the system bus.
+func systemBusAddress() string {
+	if addr := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS"); addr != "" {
+		return addr
+	}
+	return DefaultSystemBusAddress
+}
+
+// sessionBusAddress returns the address of the calling user's bus.
+func sessionBusAddress() string {
+	if addr := os.Getenv("DBUS_SESSION_BUS_ADDRESS"); addr != "" {
+		return addr
+	}
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = fmt.Sprintf("/run/user/%d", os.Getuid())
+	}
+	return "unix:path=" + filepath.Join(runtimeDir, "bus")
+}
+
+// dialDBus connects and authenticates to the bus at address. Only unix
+// transports (path= and abstract=) are supported.
+func dialDBus(address string) (*dbusConn, error) {
+	var lastErr error
+	for _, addr := range strings.Split(address, ";") {
+		socket, err := parseDBusAddress(addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		c, err := newDBusConn(conn)
+		if err != nil {
+			_ = conn.Close()
+			lastErr = err
+			continue
+		}
+		return c, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("empty address")
+	}
+	return nil, fmt.Errorf("failed to connect to D-Bus at %s: %w", address, lastErr)
+}
+
+// parseDBusAddress returns the socket of a unix transport address.
+func parseDBusAddress(addr string) (string, error) {
+	transport, params, ok := strings.Cut(addr, ":")
+	if !ok || transport != "unix" {
+		return "", fmt.Errorf("unsupported D-Bus address %q", addr)
+	}
+	for _, param := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(param, "=")
+		value, err := url.PathUnescape(value)
+		if err != nil {
+			return "", err
+		}
+		switch key {
+		case "path":
+			return value, nil
+		case "abstract":
+			return "@" + value, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported D-Bus address %q", addr)
+}
+
+// newDBusConn authenticates on conn with SASL EXTERNAL and registers with the bus.
+func newDBusConn(conn net.Conn) (*dbusConn, error) {
+	// Bound the handshake; a peer that never answers must not block forever
+	if err := conn.SetDeadline(time.Now().Add(dbusCallTimeout)); err != nil {
+		return nil, err
+	}
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := conn.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return nil, fmt.Errorf("D-Bus authentication rejected: %s", strings.TrimSpace(line))
+	}
+	if _, err := conn.Write([]byte("BEGIN\r\n")); err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	c := &dbusConn{
+		conn:        conn,
+		pending:     make(map[uint32]chan *dbusMessage),
+		subscribers: make(map[chan *dbusMessage]chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go c.readLoop(r)
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbusCallTimeout)
+	defer cancel()
+	reply, err := c.call(ctx, dbusService, dbusPath, dbusInterface, "Hello", "")
+	if err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	if c.name, err = replyValue[string](reply); err != nil {
+		_ = c.Close()
+		return nil, fmt.Errorf("failed to register with the bus: %w", err)
+	}
+	return c, nil
+}
+
+// readLoop dispatches incoming messages until the connection fails.
+func (c *dbusConn) readLoop(r io.Reader) {
+	for {
+		msg, err := readDBusMessage(r)
+		if err != nil {
+			c.mu.Lock()
+			c.err = err
+			for serial, ch := range c.pending {
+				close(ch)
+				delete(c.pending, serial)
+			}
+			c.mu.Unlock()
+			close(c.done)
+			return
+		}
+
+		switch msg.Type {
+		case dbusMethodReturn, dbusError:
+			c.mu.Lock()
+			ch, ok := c.pending[msg.ReplySerial]
+			delete(c.pending, msg.ReplySerial)
+			c.mu.Unlock()
+			if ok {
+				ch <- msg
+			}
+		case dbusSignal:
+			c.mu.Lock()
+			for ch, lost := range c.subscribers {
+				select {
+				case ch <- msg:
+				default:
+					// The subscriber is not keeping up; tell it to resync
+					select {
+					case lost <- struct{}{}:
+					default:
+					}
+				}
+			}
+			c.mu.Unlock()
+		case dbusMethodCall:
+			c.mu.Lock()
+			handler := c.handler
+			c.mu.Unlock()
+			if handler != nil {
+				go handler(msg)
+			} else if msg.Flags&dbusNoReplyExpected == 0 {
+				_ = c.reply(msg, &DBusError{Name: "org.freedesktop.DBus.Error.UnknownMethod", Message: "no handler"})
+			}
+		}
+	}
+}
+
+// send writes a message and returns its serial.
+func (c *dbusConn) send(msg *dbusMessage) (uint32, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.serial++
+	data, err := msg.marshal(c.serial)
+	if err != nil {
+		return 0, err
+	}
+	_, err = c.conn.Write(data)
+	return c.serial, err
+}
+
+// call invokes a method and waits for its reply. Error replies are returned as *DBusError.
+func (c *dbusConn) call(ctx context.Context, dest string, path dbusObjectPath, iface, member, sig string, args ...interface{}) ([]interface{}, error) {
+	ch := make(chan *dbusMessage, 1)
+	msg := &dbusMessage{Type: dbusMethodCall, Destination: dest, Path: path, Interface: iface,
+		Member: member, Signature: sig, Body: args}
+
+	// Register before sending so a fast reply is not missed
+	c.wmu.Lock()
+	serial := c.serial + 1
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		c.wmu.Unlock()
+		return nil, c.err
+	}
+	c.pending[serial] = ch
+	c.mu.Unlock()
+	c.serial = serial
+	data, err := msg.marshal(serial)
+	if err == nil {
+		_, err = c.conn.Write(data)
+	}
+	c.wmu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, serial)
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case reply, ok := <-ch:
+		if !ok {
+			return nil, fmt.Errorf("D-Bus connection closed: %w", c.err)
+		}
+		if reply.Type == dbusError {
+			e := &DBusError{Name: reply.ErrorName}
+			if len(reply.Body) > 0 {
+				e.Message, _ = reply.Body[0].(string)
+			}
+			return nil, e
+		}
+		return reply.Body, nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, serial)
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// replyValue returns the first value of a method reply, which must be of type T.
+func replyValue[T any](reply []interface{}) (T, error) {
+	var zero T
+	if len(reply) == 0 {
+		return zero, errors.New("empty D-Bus reply")
+	}
+	v, ok := reply[0].(T)
+	if !ok {
+		return zero, fmt.Errorf("unexpected D-Bus reply value %T", reply[0])
+	}
+	return v, nil
+}
+
+// reply answers a method call with values of the given signature, or with an
+// error reply if err is a *DBusError or another error.
+func (c *dbusConn) reply(call *dbusMessage, err error, sigAndValues ...interface{}) error {
+	msg := &dbusMessage{Type: dbusMethodReturn, ReplySerial: call.Serial, Destination: call.Sender}
+	if err != nil {
+		var dbusErr *DBusError
+		if !errors.As(err, &dbusErr) {
+			dbusErr = &DBusError{Name: "org.freedesktop.DBus.Error.Failed", Message: err.Error()}
+		}
+		msg.Type = dbusError
+		msg.ErrorName = dbusErr.Name
+		msg.Signature = "s"
+		msg.Body = []interface{}{dbusErr.Message}
+	} else if len(sigAndValues) > 0 {
+		msg.Signature, _ = sigAndValues[0].(string)
+		msg.Body = sigAndValues[1:]
+	}
+	_, err = c.send(msg)
+	return err
+}
+
+// emit sends a signal.
+func (c *dbusConn) emit(path dbusObjectPath, iface, member, sig string, args ...interface{}) error {
+	_, err := c.send(&dbusMessage{Type: dbusSignal, Path: path, Interface: iface, Member: member,
+		Signature: sig, Body: args})
+	return err
+}
+
+// subscribe returns a channel receiving the signals delivered to the connection
+// and a channel notified when signals were dropped because the subscriber did
+// not keep up, after which it must re-read the state it tracks. Match rules
+// must be added with addMatch for the bus to route signals.
+func (c *dbusConn) subscribe() (<-chan *dbusMessage, <-chan struct{}, func()) {
+	ch := make(chan *dbusMessage, dbusSignalBuffer)
+	lost := make(chan struct{}, 1)
+	c.mu.Lock()
+	c.subscribers[ch] = lost
+	c.mu.Unlock()
+	return ch, lost, func() {
+		c.mu.Lock()
+		delete(c.subscribers, ch)
+		c.mu.Unlock()
+	}
+}
+
+// addMatch asks the bus to route the signals matching rule to the connection.
+func (c *dbusConn) addMatch(ctx context.Context, rule string) error {
+	_, err := c.call(ctx, dbusService, dbusPath, dbusInterface, "AddMatch", "s", rule)
+	return err
+}
+
+// setHandler sets the function handling incoming method calls.
+func (c *dbusConn) setHandler(h func(*dbusMessage)) {
+	c.mu.Lock()
+	c.handler = h
+	c.mu.Unlock()
+}
+
+// Close closes the connection.
+func (c *dbusConn) Close() error {
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
@@ -0,0 +1,236 @@
+package systemd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startDBusDaemon starts a private message bus and returns its address. The
+// test is skipped when dbus-daemon is not installed.
+func startDBusDaemon(t *testing.T) string {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not available")
+	}
+
+	dir := t.TempDir()
+	conf := `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=` + filepath.Join(dir, "bus") + `</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+	confPath := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(confPath, []byte(conf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(daemon, "--nofork", "--print-address", "--config-file", confPath)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read bus address: %v", err)
+	}
+	return strings.TrimSpace(addr)
+}
+
+// TestDBusMarshalRoundTrip tests encoding and decoding of every supported type
+func TestDBusMarshalRoundTrip(t *testing.T) {
+	msg := &dbusMessage{
+		Type:      dbusMethodCall,
+		Path:      "/org/example",
+		Interface: "org.example.Test",
+		Member:    "Echo",
+		Signature: "ybnqiuxtdsogva{sv}a(so)as",
+		Body: []interface{}{
+			byte(7), true, int16(-2), uint16(3), int32(-4), uint32(5), int64(-6), uint64(7), 1.5,
+			"text", dbusObjectPath("/a/b"), dbusSignature("a{sv}"),
+			dbusVariant{Sig: "at", Value: []interface{}{uint64(1), uint64(2)}},
+			[]interface{}{[]interface{}{"key", dbusVariant{Sig: "s", Value: "value"}}},
+			[]interface{}{[]interface{}{"unit", dbusObjectPath("/u")}},
+			[]interface{}{},
+		},
+	}
+
+	data, err := msg.marshal(42)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	got, err := readDBusMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("readDBusMessage failed: %v", err)
+	}
+
+	if got.Serial != 42 || got.Path != msg.Path || got.Interface != msg.Interface ||
+		got.Member != msg.Member || got.Signature != msg.Signature {
+		t.Errorf("Expected header %+v, got %+v", msg, got)
+	}
+	if !reflect.DeepEqual(got.Body, msg.Body) {
+		t.Errorf("Expected body %#v, got %#v", msg.Body, got.Body)
+	}
+}
+
+// TestDBusMarshalErrors tests rejection of values not matching the signature
+func TestDBusMarshalErrors(t *testing.T) {
+	tests := []struct {
+		sig    string
+		values []interface{}
+	}{
+		{"s", []interface{}{42}},
+		{"ss", []interface{}{"one"}},
+		{"a{s", []interface{}{nil}},
+		{"()", []interface{}{[]interface{}{}}},
+		{"z", []interface{}{"x"}},
+	}
+	for _, tt := range tests {
+		var e dbusEncoder
+		if err := e.encode(tt.sig, tt.values); err == nil {
+			t.Errorf("Expected error encoding %v as %q", tt.values, tt.sig)
+		}
+	}
+}
+
+// TestDBusDecodeInvalidVariant tests that variants without exactly one complete type are rejected
+func TestDBusDecodeInvalidVariant(t *testing.T) {
+	for _, sig := range []string{"", "ss", "a"} {
+		// Signature length, signature, NUL and padding before a uint32 value
+		data := append([]byte{byte(len(sig))}, sig...)
+		data = append(data, 0)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+		data = append(data, 1, 0, 0, 0, 2, 0, 0, 0)
+
+		d := &dbusDecoder{data: data, order: binary.LittleEndian}
+		if _, err := d.decode("v", 0); err == nil {
+			t.Errorf("Expected error decoding variant with signature %q", sig)
+		}
+	}
+	if _, err := (&dbusDecoder{order: binary.LittleEndian}).decodeValue("", 0); err == nil {
+		t.Error("Expected error decoding an empty type")
+	}
+}
+
+// TestDBusDecodeNestedVariants tests that variant nesting is limited
+func TestDBusDecodeNestedVariants(t *testing.T) {
+	nested := func(n int) []byte {
+		var data []byte
+		for i := 0; i < n; i++ {
+			data = append(data, 1, 'v', 0)
+		}
+		return append(data, 1, 'y', 0, 7)
+	}
+
+	d := &dbusDecoder{data: nested(10), order: binary.LittleEndian}
+	if _, err := d.decode("v", 0); err != nil {
+		t.Errorf("Expected shallow variants to decode, got %v", err)
+	}
+	d = &dbusDecoder{data: nested(10000), order: binary.LittleEndian}
+	if _, err := d.decode("v", 0); err == nil || !strings.Contains(err.Error(), "nested deeper") {
+		t.Errorf("Expected nesting error, got %v", err)
+	}
+}
+
+// TestReadDBusMessageTruncated tests that a truncated message is an error
+func TestReadDBusMessageTruncated(t *testing.T) {
+	msg := &dbusMessage{Type: dbusSignal, Path: "/p", Interface: "a.b", Member: "C", Signature: "s", Body: []interface{}{"x"}}
+	data, err := msg.marshal(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readDBusMessage(bytes.NewReader(data[:len(data)-3])); err == nil {
+		t.Error("Expected error for truncated message")
+	}
+}
+
+// TestParseDBusAddress tests unix transport address parsing
+func TestParseDBusAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		want    string
+		wantErr bool
+	}{
+		{"unix:path=/run/dbus/system_bus_socket", "/run/dbus/system_bus_socket", false},
+		{"unix:path=/tmp/with%20space,guid=abc", "/tmp/with space", false},
+		{"unix:abstract=/tmp/dbus-x,guid=abc", "@/tmp/dbus-x", false},
+		{"tcp:host=localhost,port=1", "", true},
+		{"unix:guid=abc", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseDBusAddress(tt.addr)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseDBusAddress(%q): expected %q (error %v), got %q (%v)", tt.addr, tt.want, tt.wantErr, got, err)
+		}
+	}
+}
+
+// TestDialDBus tests authentication and method calls against a real bus
+func TestDialDBus(t *testing.T) {
+	addr := startDBusDaemon(t)
+
+	conn, err := dialDBus(addr)
+	if err != nil {
+		t.Fatalf("dialDBus failed: %v", err)
+	}
+	defer conn.Close()
+	if !strings.HasPrefix(conn.name, ":") {
+		t.Errorf("Expected unique bus name, got %q", conn.name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := conn.call(ctx, dbusService, dbusPath, dbusInterface, "ListNames", "")
+	if err != nil {
+		t.Fatalf("ListNames failed: %v", err)
+	}
+	found := false
+	for _, name := range reply[0].([]interface{}) {
+		found = found || name == conn.name
+	}
+	if !found {
+		t.Errorf("Expected %s in %v", conn.name, reply[0])
+	}
+
+	_, err = conn.call(ctx, "org.example.Missing", "/", "org.example.Missing", "Foo", "")
+	var dbusErr *DBusError
+	if !errors.As(err, &dbusErr) || dbusErr.Name != "org.freedesktop.DBus.Error.ServiceUnknown" {
+		t.Errorf("Expected ServiceUnknown error, got %v", err)
+	}
+}
+
+// TestDialDBusFailure tests the error for an unreachable bus
+func TestDialDBusFailure(t *testing.T) {
+	_, err := dialDBus("unix:path=" + filepath.Join(t.TempDir(), "missing"))
+	if err == nil || !strings.Contains(err.Error(), "failed to connect to D-Bus") {
+		t.Errorf("Expected connection error, got %v", err)
+	}
+}
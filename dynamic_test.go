@@ -0,0 +1,103 @@
+package systemd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestWithDynamicUser tests the DynamicUser option and managed directories
+func TestWithDynamicUser(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/test", "/var/log/test",
+		WithDynamicUser(),
+		WithStateDirectory("test", 0o750),
+		WithCacheDirectory("test", 0),
+		WithLogsDirectory("test", 0o750),
+		WithRuntimeDirectory("test/sockets", 0o755),
+		WithConfigurationDirectory("test", 0o700),
+	)
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	if !cfg.DynamicUser {
+		t.Error("Expected DynamicUser to be true")
+	}
+
+	expected := []string{
+		"DynamicUser=yes",
+		"StateDirectory=test",
+		"StateDirectoryMode=0750",
+		"CacheDirectory=test",
+		"LogsDirectory=test",
+		"LogsDirectoryMode=0750",
+		"RuntimeDirectory=test/sockets",
+		"RuntimeDirectoryMode=0755",
+		"ConfigurationDirectory=test",
+		"ConfigurationDirectoryMode=0700",
+	}
+	if !reflect.DeepEqual(cfg.ServiceLines, expected) {
+		t.Errorf("Expected ServiceLines %v, got %v", expected, cfg.ServiceLines)
+	}
+}
+
+// TestDynamicUserUnit tests that the unit names no static account with DynamicUser
+func TestDynamicUserUnit(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/test", "", WithDynamicUser())
+	cfg.SystemdFile = filepath.Join(t.TempDir(), "test.service")
+	if err := writeSystemdUnit(&cfg); err != nil {
+		t.Fatalf("writeSystemdUnit failed: %v", err)
+	}
+
+	unit, err := os.ReadFile(cfg.SystemdFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(unit), "\n") {
+		if strings.HasPrefix(line, "User=") || strings.HasPrefix(line, "Group=") {
+			t.Errorf("Expected no %s with DynamicUser:\n%s", line, unit)
+		}
+	}
+	if !strings.Contains(string(unit), "DynamicUser=yes") {
+		t.Errorf("Expected DynamicUser=yes in unit:\n%s", unit)
+	}
+}
+
+// TestManagedDirectoryValidation tests rejection of invalid managed directories
+func TestManagedDirectoryValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  ServiceOpt
+	}{
+		{"absolute", WithStateDirectory("/var/lib/test", 0)},
+		{"parent", WithCacheDirectory("../test", 0)},
+		{"empty", WithLogsDirectory("", 0)},
+		{"spaces", WithRuntimeDirectory("my dir", 0)},
+		{"mode", WithConfigurationDirectory("test", os.ModeDir|0o755)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ServiceConfig{}
+			tt.opt(&cfg)
+			if err := cfg.Validate(); err == nil {
+				t.Error("Expected validation error, got nil")
+			}
+		})
+	}
+}
+
+// TestLogOwner tests log file ownership for static and dynamic users
+func TestLogOwner(t *testing.T) {
+	cfg := ServiceConfig{User: "svc", Group: "svcgrp"}
+	if user, group := logOwner(&cfg); user != "svc" || group != "svcgrp" {
+		t.Errorf("Expected svc:svcgrp, got %s:%s", user, group)
+	}
+
+	WithDynamicUser()(&cfg)
+	if user, group := logOwner(&cfg); user != "root" || group != "root" {
+		t.Errorf("Expected root:root for dynamic user, got %s:%s", user, group)
+	}
+}
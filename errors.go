@@ -0,0 +1,143 @@
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+var (
+	// ErrNotRoot is returned when an operation needs root privileges the caller lacks.
+	ErrNotRoot = errors.New("root privileges required")
+
+	// ErrSystemdUnavailable is returned when systemd cannot be reached, e.g. in a
+	// container not booted with systemd or when systemctl is not installed.
+	ErrSystemdUnavailable = errors.New("systemd is not available")
+)
+
+// Step identifies a stage of a Manager operation in a StepError.
+type Step string
+
+// Steps of Install, Uninstall and the lifecycle operations.
+const (
+	StepPreflight Step = "preflight" // Privilege checks
+	StepValidate  Step = "validate"  // Configuration validation
+	StepAccount   Step = "account"   // Service account, sysusers.d or user unit directory
+	StepTmpfiles  Step = "tmpfiles"  // tmpfiles.d configuration
+	StepLogDir    Step = "logdir"    // Log directory provisioning
+	StepRsyslog   Step = "rsyslog"   // Rsyslog configuration
+	StepLogrotate Step = "logrotate" // Logrotate configuration
+	StepJournald  Step = "journald"  // Journal namespace configuration
+	StepSlice     Step = "slice"     // Slice unit file
+	StepUnit      Step = "unit"      // Service unit file
+	StepConnect   Step = "connect"   // Connecting to the service manager
+	StepReload    Step = "reload"    // Daemon reload
+	StepEnable    Step = "enable"    // Enabling the service
+	StepDisable   Step = "disable"   // Disabling the service
+	StepStart     Step = "start"     // Starting the service
+	StepStop      Step = "stop"      // Stopping the service
+	StepRestart   Step = "restart"   // Restarting the service
+	StepRemove    Step = "remove"    // Removing configuration files
+)
+
+// StepError reports the step of a Manager operation that failed.
+type StepError struct {
+	Step Step
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *StepError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Step, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// CommandError reports a failed external command.
+type CommandError struct {
+	Cmd      string
+	Args     []string
+	ExitCode int // Exit status, -1 if the command did not run or was killed
+	Stdout   string
+	Stderr   string
+	Err      error // Underlying error, e.g. *exec.ExitError or exec.ErrNotFound
+}
+
+// Error implements the error interface.
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("command '%s %s' failed: %v\nOutput: %s",
+		e.Cmd, strings.Join(e.Args, " "), e.Err, e.Stdout+e.Stderr)
+}
+
+// Unwrap returns the underlying error, so errors.Is(err, exec.ErrNotFound)
+// reports a missing command.
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// notRootMarkers are messages printed by systemd tools when polkit or the
+// service manager denies an operation to an unprivileged caller. Other tools
+// print "Permission denied" for any unreadable file, so their failures are not
+// classified.
+var notRootMarkers = []string{
+	"Interactive authentication required",
+	"Access denied",
+}
+
+// systemdUnavailableMarkers are messages printed by systemd tools that cannot
+// reach the service manager.
+var systemdUnavailableMarkers = []string{
+	"System has not been booted with systemd",
+	"Failed to connect to bus",
+	"Failed to connect to system scope bus",
+	"Failed to connect to user scope bus",
+}
+
+// systemdTools are the commands whose absence means systemd is not installed.
+var systemdTools = map[string]bool{
+	"systemctl":        true,
+	"journalctl":       true,
+	"loginctl":         true,
+	"systemd-sysusers": true,
+	"systemd-tmpfiles": true,
+}
+
+// Is classifies the failure: ErrNotRoot when systemd denies a systemd tool the
+// operation, and ErrSystemdUnavailable when a systemd tool is missing or cannot
+// reach systemd.
+func (e *CommandError) Is(target error) bool {
+	switch target {
+	case ErrNotRoot:
+		return systemdTools[e.Cmd] && containsAny(e.Stderr, notRootMarkers)
+	case ErrSystemdUnavailable:
+		if systemdTools[e.Cmd] && errors.Is(e.Err, exec.ErrNotFound) {
+			return true
+		}
+		return containsAny(e.Stderr, systemdUnavailableMarkers)
+	}
+	return false
+}
+
+// containsAny reports whether s contains any of the markers.
+func containsAny(s string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.Contains(s, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// newCommandError builds a CommandError, taking the exit code from err.
+func newCommandError(cmd string, args []string, stdout, stderr string, err error) *CommandError {
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+	return &CommandError{Cmd: cmd, Args: args, ExitCode: exitCode, Stdout: stdout, Stderr: stderr, Err: err}
+}
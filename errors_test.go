@@ -0,0 +1,110 @@
+package systemd
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestCommandError tests the fields and classification of failed commands
+func TestCommandError(t *testing.T) {
+	fakeCommands(t, map[string]string{
+		"useradd":   `echo "partial"; echo "useradd: Permission denied." >&2; exit 1`,
+		"systemctl": `echo "System has not been booted with systemd as init system (PID 1). Can't operate." >&2; exit 1`,
+	})
+
+	err := execCommand("useradd", "--system", "app")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("Expected CommandError, got %v", err)
+	}
+	if cmdErr.Cmd != "useradd" || len(cmdErr.Args) != 2 || cmdErr.ExitCode != 1 {
+		t.Errorf("Unexpected command error fields: %+v", cmdErr)
+	}
+	if cmdErr.Stdout != "partial\n" || cmdErr.Stderr != "useradd: Permission denied.\n" {
+		t.Errorf("Expected separate stdout and stderr, got %q and %q", cmdErr.Stdout, cmdErr.Stderr)
+	}
+	if errors.Is(err, ErrNotRoot) || errors.Is(err, ErrSystemdUnavailable) {
+		t.Errorf("Expected unclassified useradd failure: %v", err)
+	}
+
+	err = execCommand("systemctl", "daemon-reload")
+	if !errors.Is(err, ErrSystemdUnavailable) || errors.Is(err, ErrNotRoot) {
+		t.Errorf("Expected systemctl failure to match only ErrSystemdUnavailable: %v", err)
+	}
+}
+
+// TestCommandErrorNotRoot tests that only privilege checks of systemd match ErrNotRoot
+func TestCommandErrorNotRoot(t *testing.T) {
+	fakeCommands(t, map[string]string{
+		"systemctl": `echo "Failed to start app.service: Interactive authentication required." >&2; exit 1`,
+		"loginctl":  `echo "Could not enable linger: Access denied" >&2; exit 1`,
+		"rsyslogd":  `echo "rsyslogd: file '/etc/ssl/key.pem': open error: Permission denied" >&2; exit 1`,
+		"logrotate": `echo "error: stat of /var/log/app failed: Operation not permitted" >&2; exit 1`,
+	})
+
+	for _, cmd := range []string{"systemctl", "loginctl"} {
+		if err := execCommand(cmd, "start"); !errors.Is(err, ErrNotRoot) {
+			t.Errorf("Expected %s failure to match ErrNotRoot: %v", cmd, err)
+		}
+	}
+	for _, cmd := range []string{"rsyslogd", "logrotate"} {
+		if err := execCommand(cmd, "-N1"); errors.Is(err, ErrNotRoot) {
+			t.Errorf("Expected %s failure not to match ErrNotRoot: %v", cmd, err)
+		}
+	}
+}
+
+// TestCommandErrorNotFound tests errors for commands that are not installed
+func TestCommandErrorNotFound(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	err := execCommand("systemctl", "daemon-reload")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.ExitCode != -1 {
+		t.Fatalf("Expected CommandError without exit code, got %v", err)
+	}
+	if !errors.Is(err, exec.ErrNotFound) || !errors.Is(err, ErrSystemdUnavailable) {
+		t.Errorf("Expected missing systemctl to match exec.ErrNotFound and ErrSystemdUnavailable: %v", err)
+	}
+
+	err = execCommand("useradd", "app")
+	if !errors.Is(err, exec.ErrNotFound) || errors.Is(err, ErrSystemdUnavailable) {
+		t.Errorf("Expected missing useradd to match only exec.ErrNotFound: %v", err)
+	}
+}
+
+// TestStepError tests that Manager operations report the failing step
+func TestStepError(t *testing.T) {
+	fakeCommands(t, map[string]string{"systemctl": `echo "Access denied" >&2; exit 1`})
+
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"})
+	_, err := m.Stop()
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != StepStop {
+		t.Fatalf("Expected StepError for %s, got %v", StepStop, err)
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || !errors.Is(err, ErrNotRoot) {
+		t.Errorf("Expected wrapped CommandError matching ErrNotRoot, got %v", err)
+	}
+
+	cfg := NewServiceConfig("testuser", "testgroup", "/usr/bin/test", "", WithCPUWeight(0))
+	err = NewManager(&cfg).Install()
+	if !errors.As(err, &stepErr) || stepErr.Step != StepValidate {
+		t.Errorf("Expected StepError for %s, got %v", StepValidate, err)
+	}
+}
+
+// TestDBusUnavailable tests that an unreachable bus is reported as ErrSystemdUnavailable
+func TestDBusUnavailable(t *testing.T) {
+	addr := "unix:path=" + filepath.Join(t.TempDir(), "missing")
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"}, WithBusAddress(addr))
+
+	_, err := m.Restart()
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != StepConnect || !errors.Is(err, ErrSystemdUnavailable) {
+		t.Errorf("Expected connect step error matching ErrSystemdUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package systemd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+// Event types delivered to WithEventChan and WithEventHandler.
+const (
+	EventStepStarted  EventType = "step-started"  // A step began
+	EventStepFinished EventType = "step-finished" // A step completed; Duration is set
+	EventStepSkipped  EventType = "step-skipped"  // A step does not apply; Message gives the reason
+	EventStepFailed   EventType = "step-failed"   // A step failed; Err is a *StepError
+	EventFileWritten  EventType = "file-written"  // A file was written; Path and line stats are set
+	EventFileRemoved  EventType = "file-removed"  // A file was removed; Path is set
+	EventCommand      EventType = "command"       // A command ran; Command, Duration and Err are set
+	EventInfo         EventType = "info"          // Informational message
+	EventWarning      EventType = "warning"       // Non-fatal problem; Err is set
+)
+
+// Event is a structured progress report of a Manager operation. Events raised
+// while a step runs carry the step and its position in the operation.
+type Event struct {
+	Type         EventType
+	Time         time.Time
+	Step         Step          // Step the event belongs to, empty outside steps
+	Index        int           // 1-based position of Step in the operation
+	Total        int           // Number of steps of the operation, including skipped ones
+	Message      string        // Human readable description
+	Path         string        // File written or removed
+	LinesAdded   int           // Lines added to the file compared to its previous content
+	LinesRemoved int           // Lines removed from the file compared to its previous content
+	Command      []string      // Command and arguments
+	Duration     time.Duration // Duration of the command or step
+	Err          error         // Error of step-failed, failed command and warning events
+}
+
+// WithEventChan configures the Manager to send structured events to the channel.
+// Events are sent non-blocking - if the channel is full, the event is dropped and
+// counted by Dropped, unless WithBlockingDelivery is set.
+func WithEventChan(ch chan<- Event) Option {
+	return func(m *Manager) { m.eventChan = ch }
+}
+
+// WithEventHandler configures the Manager to call fn synchronously for every
+// event. fn must not call back into the Manager.
+func WithEventHandler(fn func(Event)) Option {
+	return func(m *Manager) { m.eventHandler = fn }
+}
+
+// emit delivers an event to the event handler, observers and channel. Info
+// messages and skipped steps are also sent to the info channel, warnings and
+// failures to the error channel.
+func (m *Manager) emit(e Event) {
+	e.Time = time.Now()
+	if e.Step == "" {
+		m.mu.Lock()
+		e.Step, e.Index, e.Total = m.step, m.stepIndex, m.stepTotal
+		m.mu.Unlock()
+	}
+
+	if m.eventHandler != nil {
+		m.eventHandler(e)
+	}
+	for _, o := range m.observers {
+		o.OnEvent(e)
+	}
+	deliver(m, m.eventChan, e)
+
+	switch e.Type {
+	case EventInfo, EventStepSkipped:
+		for _, o := range m.observers {
+			o.OnInfo(e.Message)
+		}
+		deliver(m, m.infoChan, e.Message)
+	case EventWarning, EventStepFailed:
+		for _, o := range m.observers {
+			o.OnError(e.Err)
+		}
+		deliver(m, m.errChan, e.Err)
+	}
+}
+
+// step is a stage of a Manager operation. skip holds the reason the step does
+// not apply, which is reported instead of running it.
+type step struct {
+	name Step
+	skip string
+	run  func() error
+}
+
+// skipUnless returns reason if cond is false, for use as step.skip.
+func skipUnless(cond bool, reason string) string {
+	if cond {
+		return ""
+	}
+	return reason
+}
+
+// runSteps runs the steps in order, reporting their progress, and stops at the
+// first failure, which is returned as *StepError.
+func (m *Manager) runSteps(steps []step) error {
+	defer m.setStep("", 0, 0)
+
+	for i, s := range steps {
+		m.setStep(s.name, i+1, len(steps))
+		if s.skip != "" {
+			m.emit(Event{Type: EventStepSkipped, Message: fmt.Sprintf("Skipping %s: %s", s.name, s.skip)})
+			continue
+		}
+
+		m.emit(Event{Type: EventStepStarted, Message: fmt.Sprintf("Step %d/%d: %s", i+1, len(steps), s.name)})
+		start := time.Now()
+		if err := s.run(); err != nil {
+			return m.failStep(s.name, err)
+		}
+		m.emit(Event{Type: EventStepFinished, Duration: time.Since(start)})
+	}
+	return nil
+}
+
+// setStep records the step in progress for the events it raises.
+func (m *Manager) setStep(name Step, index, total int) {
+	m.mu.Lock()
+	m.step, m.stepIndex, m.stepTotal = name, index, total
+	m.mu.Unlock()
+}
+
+// emit reports an event to the Manager owning the configuration, if any.
+func (c *ServiceConfig) emit(e Event) {
+	if c.events != nil {
+		c.events(e)
+	}
+}
+
+// execCommand runs a command like the package-level execCommand and reports it.
+func (c *ServiceConfig) execCommand(cmd string, args ...string) error {
+	_, _, err := c.runCommand(cmd, args...)
+	return err
+}
+
+// runCommand runs a command like the package-level runCommand and reports it.
+func (c *ServiceConfig) runCommand(cmd string, args ...string) ([]byte, []byte, error) {
+	start := time.Now()
+	stdout, stderr, err := runCommand(cmd, args...)
+	c.emit(Event{
+		Type:     EventCommand,
+		Message:  strings.Join(append([]string{cmd}, args...), " "),
+		Command:  append([]string{cmd}, args...),
+		Duration: time.Since(start),
+		Err:      err,
+	})
+	return stdout, stderr, err
+}
+
+// writeFile writes a configuration file with configFileMode and reports the
+// lines changed compared to its previous content.
+func (c *ServiceConfig) writeFile(path string, content []byte) error {
+	previous, _ := os.ReadFile(path) // #nosec G304
+
+	if err := os.WriteFile(path, content, configFileMode); err != nil { // #nosec G306
+		return err
+	}
+	added, removed := diffLines(previous, content)
+	c.emit(Event{
+		Type:         EventFileWritten,
+		Message:      fmt.Sprintf("Wrote %s (+%d -%d)", path, added, removed),
+		Path:         path,
+		LinesAdded:   added,
+		LinesRemoved: removed,
+	})
+	return nil
+}
+
+// removeFile removes a file and reports it if it existed.
+func (c *ServiceConfig) removeFile(path string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	c.emit(Event{Type: EventFileRemoved, Message: "Removed " + path, Path: path})
+	return nil
+}
+
+// diffLines counts the lines of next missing from prev and the lines of prev
+// missing from next, ignoring their order.
+func diffLines(prev, next []byte) (added, removed int) {
+	counts := make(map[string]int)
+	for _, line := range splitLines(prev) {
+		counts[line]++
+	}
+	for _, line := range splitLines(next) {
+		if counts[line] > 0 {
+			counts[line]--
+		} else {
+			added++
+		}
+	}
+	for _, n := range counts {
+		removed += n
+	}
+	return added, removed
+}
+
+// splitLines splits content into lines without their terminators.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(string(bytes.TrimSuffix(content, []byte("\n"))), "\n")
+}
@@ -0,0 +1,142 @@
+package systemd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDiffLines tests line diff statistics
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		prev, next     string
+		added, removed int
+	}{
+		{"", "a\nb\n", 2, 0},
+		{"a\nb\n", "", 0, 2},
+		{"a\nb\n", "a\nb\n", 0, 0},
+		{"a\nb\nc\n", "a\nx\nc\n", 1, 1},
+		{"a\nb", "b\na\nc\n", 1, 0},
+	}
+	for _, tt := range tests {
+		added, removed := diffLines([]byte(tt.prev), []byte(tt.next))
+		if added != tt.added || removed != tt.removed {
+			t.Errorf("diffLines(%q, %q): expected +%d -%d, got +%d -%d",
+				tt.prev, tt.next, tt.added, tt.removed, added, removed)
+		}
+	}
+}
+
+// TestInstallEvents tests the structured events reported during Install
+func TestInstallEvents(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	fakeCommands(t, map[string]string{"systemctl": "exit 0"})
+
+	var events []Event
+	infoChan := make(chan string, 100)
+	cfg := NewServiceConfig("nobody", "nogroup", "/usr/bin/myapp", "")
+	m := NewManager(&cfg, WithUserScope(), WithInfoChan(infoChan),
+		WithEventHandler(func(e Event) { events = append(events, e) }))
+	if err := m.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	var started, skipped []Step
+	var written, commands []Event
+	for _, e := range events {
+		if e.Time.IsZero() {
+			t.Errorf("Expected event time for %+v", e)
+		}
+		switch e.Type {
+		case EventStepStarted:
+			started = append(started, e.Step)
+		case EventStepSkipped:
+			skipped = append(skipped, e.Step)
+		case EventFileWritten:
+			written = append(written, e)
+		case EventCommand:
+			commands = append(commands, e)
+		}
+		if e.Type != EventInfo && e.Step != "" && (e.Total != 14 || e.Index < 1 || e.Index > e.Total) {
+			t.Errorf("Expected step position within 14 steps, got %d/%d for %+v", e.Index, e.Total, e)
+		}
+	}
+
+	wantStarted := []Step{StepValidate, StepAccount, StepUnit, StepConnect, StepReload, StepEnable, StepStart}
+	if strings.Join(stepNames(started), ",") != strings.Join(stepNames(wantStarted), ",") {
+		t.Errorf("Expected started steps %v, got %v", wantStarted, started)
+	}
+	if len(skipped) != 14-len(wantStarted) {
+		t.Errorf("Expected %d skipped steps, got %v", 14-len(wantStarted), skipped)
+	}
+
+	unitPath := filepath.Join(configHome, "systemd", "user", "bin-myapp.service")
+	if len(written) != 1 || written[0].Path != unitPath || written[0].Step != StepUnit || written[0].Index != 10 {
+		t.Fatalf("Expected unit file written in step 10, got %+v", written)
+	}
+	unit, _ := os.ReadFile(unitPath)
+	if written[0].LinesAdded != len(splitLines(unit)) || written[0].LinesRemoved != 0 {
+		t.Errorf("Expected +%d -0 for a new file, got +%d -%d", len(splitLines(unit)), written[0].LinesAdded, written[0].LinesRemoved)
+	}
+
+	if len(commands) == 0 || strings.Join(commands[0].Command, " ") != "systemctl --user daemon-reload" ||
+		commands[0].Step != StepReload || commands[0].Err != nil {
+		t.Errorf("Expected daemon-reload command in the reload step, got %+v", commands)
+	}
+
+	// The string channel keeps receiving the informational messages
+	close(infoChan)
+	var messages []string
+	for msg := range infoChan {
+		messages = append(messages, msg)
+	}
+	joined := strings.Join(messages, "\n")
+	for _, want := range []string{"Systemd unit file written", "Skipping preflight: user scope"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected %q in info messages:\n%s", want, joined)
+		}
+	}
+}
+
+// TestEventChanStepFailed tests failure events on the event and error channels
+func TestEventChanStepFailed(t *testing.T) {
+	cfg := NewServiceConfig("testuser", "testgroup", "/usr/bin/test", "", WithCPUWeight(0))
+	eventChan := make(chan Event, 10)
+	errChan := make(chan error, 10)
+	m := NewManager(&cfg, WithEventChan(eventChan), WithErrorChan(errChan))
+
+	if err := m.Install(); err == nil {
+		t.Fatal("Expected Install to fail")
+	}
+	close(eventChan)
+
+	var failed *Event
+	for e := range eventChan {
+		if e.Type == EventStepFailed {
+			failed = &e
+		}
+	}
+	var stepErr *StepError
+	if failed == nil || failed.Step != StepValidate || failed.Index != 1 || !errors.As(failed.Err, &stepErr) {
+		t.Fatalf("Expected step-failed event for validate, got %+v", failed)
+	}
+	select {
+	case err := <-errChan:
+		if !errors.As(err, &stepErr) {
+			t.Errorf("Expected StepError on the error channel, got %v", err)
+		}
+	default:
+		t.Error("Expected error on the error channel")
+	}
+}
+
+func stepNames(steps []Step) []string {
+	names := make([]string, len(steps))
+	for i, s := range steps {
+		names[i] = string(s)
+	}
+	return names
+}
@@ -0,0 +1,446 @@
+package systemd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// forwardProbeTimeout bounds the reachability check of each forward target during Install.
+const forwardProbeTimeout = 5 * time.Second
+
+// ForwardProtocol selects the transport used to forward log streams.
+type ForwardProtocol string
+
+const (
+	ForwardTCP  ForwardProtocol = "tcp"  // Plain or TLS syslog over TCP (omfwd)
+	ForwardUDP  ForwardProtocol = "udp"  // Syslog over UDP (omfwd)
+	ForwardRELP ForwardProtocol = "relp" // Reliable Event Logging Protocol (omrelp)
+)
+
+// ForwardTLS configures TLS for a forward target. Over TCP this produces
+// RFC 5425 syslog over TLS with octet-counted framing.
+type ForwardTLS struct {
+	CAFile         string   // CA certificate used to verify the collector
+	CertFile       string   // Client certificate (optional, for mutual TLS)
+	KeyFile        string   // Client private key (optional, for mutual TLS)
+	AuthMode       string   // Peer authentication: "x509/name" (default), "x509/fingerprint", "x509/certvalid" or "anon"
+	PermittedPeers []string // Accepted peer names or fingerprints
+}
+
+// ForwardQueue configures the action queue of a forward target. Setting FileName
+// turns the in-memory queue into a disk-assisted queue that survives collector
+// outages and, with SaveOnShutdown, rsyslog restarts.
+type ForwardQueue struct {
+	Size           int    // Maximum number of queued messages in memory (0 for rsyslog default)
+	FileName       string // Spool file prefix in rsyslog's work directory (enables disk assistance)
+	MaxDiskSpace   string // Maximum spool size, e.g. "1G"
+	SaveOnShutdown bool   // Persist queued messages when rsyslog stops
+}
+
+// ForwardTarget describes a remote collector receiving log streams.
+type ForwardTarget struct {
+	Protocol ForwardProtocol // Transport (defaults to TCP)
+	Host     string          // Collector host name or address
+	Port     int             // Collector port (defaults to 514, 6514 with TLS, 2514 for RELP)
+	Template string          // rsyslog template (defaults to RSYSLOG_SyslogProtocol23Format)
+	TLS      *ForwardTLS     // TLS settings (optional)
+	Queue    *ForwardQueue   // Action queue settings (optional)
+}
+
+// WithForward forwards every log stream to the given collector in addition to
+// writing it to its file.
+func WithForward(t ForwardTarget) ServiceOpt {
+	return func(c *ServiceConfig) {
+		c.Forwards = append(c.Forwards, t)
+	}
+}
+
+// WithStreamForward forwards a single log stream to the given collector.
+func WithStreamForward(stream string, t ForwardTarget) ServiceOpt {
+	return func(c *ServiceConfig) {
+		if c.StreamForwards == nil {
+			c.StreamForwards = make(map[string][]ForwardTarget)
+		}
+		c.StreamForwards[stream] = append(c.StreamForwards[stream], t)
+	}
+}
+
+// Address returns the host:port the target connects to.
+func (t *ForwardTarget) Address() string {
+	return net.JoinHostPort(t.Host, strconv.Itoa(t.port()))
+}
+
+// Probe checks that the collector accepts connections, including the TLS
+// handshake when TLS is configured. UDP targets are only resolved.
+func (t *ForwardTarget) Probe(ctx context.Context) error {
+	if t.protocol() == ForwardUDP {
+		_, err := net.DefaultResolver.LookupHost(ctx, t.Host)
+		return err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.Address())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	if t.TLS == nil {
+		return nil
+	}
+
+	tlsConf, err := t.TLS.clientConfig(t.Host)
+	if err != nil {
+		return err
+	}
+	return tls.Client(conn, tlsConf).HandshakeContext(ctx)
+}
+
+// clientConfig builds a Go TLS configuration equivalent to the rsyslog settings.
+func (t *ForwardTLS) clientConfig(host string) (*tls.Config, error) {
+	conf := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if t.AuthMode == "anon" {
+		conf.InsecureSkipVerify = true // #nosec G402 -- mirrors rsyslog's anonymous mode
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", t.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+func (t *ForwardTarget) protocol() ForwardProtocol {
+	if t.Protocol == "" {
+		return ForwardTCP
+	}
+	return t.Protocol
+}
+
+func (t *ForwardTarget) port() int {
+	switch {
+	case t.Port != 0:
+		return t.Port
+	case t.protocol() == ForwardRELP:
+		return 2514
+	case t.TLS != nil:
+		return 6514
+	default:
+		return 514
+	}
+}
+
+// validate checks a forward target for settings rsyslog would reject.
+func (t *ForwardTarget) validate() error {
+	switch t.protocol() {
+	case ForwardTCP, ForwardUDP, ForwardRELP:
+	default:
+		return fmt.Errorf("unknown protocol %q", t.Protocol)
+	}
+	if t.Host == "" || strings.ContainsAny(t.Host, "\" \t\n") {
+		return fmt.Errorf("invalid host %q", t.Host)
+	}
+	if t.Port < 0 || t.Port > 65535 {
+		return fmt.Errorf("invalid port %d", t.Port)
+	}
+	if t.TLS != nil && t.protocol() == ForwardUDP {
+		return fmt.Errorf("TLS is not supported over UDP")
+	}
+	if t.TLS != nil {
+		switch t.TLS.AuthMode {
+		case "", "x509/name", "x509/fingerprint", "x509/certvalid", "anon":
+		default:
+			return fmt.Errorf("unknown TLS auth mode %q", t.TLS.AuthMode)
+		}
+		if (t.TLS.CertFile == "") != (t.TLS.KeyFile == "") {
+			return fmt.Errorf("TLS certificate and key must be set together")
+		}
+		for name, value := range map[string]string{"CA file": t.TLS.CAFile, "certificate": t.TLS.CertFile, "key": t.TLS.KeyFile} {
+			if !safeRainerValue(value) {
+				return fmt.Errorf("invalid TLS %s %q", name, value)
+			}
+		}
+		for _, peer := range t.TLS.PermittedPeers {
+			if peer == "" || !safeRainerValue(peer) || strings.Contains(peer, ",") {
+				return fmt.Errorf("invalid permitted peer %q", peer)
+			}
+		}
+	}
+	if !safeRainerValue(t.Template) {
+		return fmt.Errorf("invalid template %q", t.Template)
+	}
+	if t.Queue != nil && !safeRainerValue(t.Queue.FileName) {
+		return fmt.Errorf("invalid queue file name %q", t.Queue.FileName)
+	}
+	if t.Queue != nil && t.Queue.MaxDiskSpace != "" {
+		if _, err := ParseSize(t.Queue.MaxDiskSpace, false); err != nil {
+			return fmt.Errorf("queue: %w", err)
+		}
+	}
+	return nil
+}
+
+// safeRainerValue reports whether s can be placed inside a double-quoted
+// RainerScript parameter value without escaping.
+func safeRainerValue(s string) bool {
+	return !strings.ContainsAny(s, "\"\\\n\r")
+}
+
+// validateForwards checks all configured forward targets.
+func validateForwards(c *ServiceConfig) []error {
+	var errs []error
+	for _, t := range c.Forwards {
+		if err := t.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("forward %s: %w", t.Host, err))
+		}
+	}
+	for stream, targets := range c.StreamForwards {
+		if _, ok := c.Streams[stream]; !ok {
+			errs = append(errs, fmt.Errorf("forward for unknown stream %q", stream))
+		}
+		for _, t := range targets {
+			if err := t.validate(); err != nil {
+				errs = append(errs, fmt.Errorf("stream %q forward %s: %w", stream, t.Host, err))
+			}
+		}
+	}
+	return errs
+}
+
+// streamForwards returns the targets a stream is forwarded to.
+func streamForwards(c *ServiceConfig, stream string) []ForwardTarget {
+	return append(append([]ForwardTarget(nil), c.Forwards...), c.StreamForwards[stream]...)
+}
+
+// probeForwards checks that every forward target accepts connections. Unreachable
+// collectors are reported on the error channel without failing the installation,
+// since rsyslog retries and queues messages until the collector is available.
+// Targets are probed concurrently so unreachable collectors delay Install by at
+// most forwardProbeTimeout.
+func (m *Manager) probeForwards() {
+	c := m.cfg
+	var keys []string
+	targets := make(map[string]ForwardTarget)
+	for _, name := range sortedStreams(c.Streams) {
+		for _, t := range streamForwards(c, name) {
+			key := string(t.protocol()) + "://" + t.Address()
+			if _, ok := targets[key]; !ok {
+				keys = append(keys, key)
+				targets[key] = t
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), forwardProbeTimeout)
+	defer cancel()
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, t ForwardTarget) {
+			defer wg.Done()
+			errs[i] = t.Probe(ctx)
+		}(i, targets[key])
+	}
+	wg.Wait()
+
+	// Report in configuration order from the installing goroutine
+	for i, key := range keys {
+		if errs[i] != nil {
+			m.error(fmt.Errorf("forward target %s unreachable: %w", key, errs[i]))
+		} else {
+			m.infof("Forward target reachable: %s", key)
+		}
+	}
+}
+
+// renderForwardAction renders the rsyslog action forwarding to the target.
+func renderForwardAction(t *ForwardTarget) string {
+	template := t.Template
+	if template == "" {
+		template = "RSYSLOG_SyslogProtocol23Format"
+	}
+
+	var params []string
+	add := func(key, value string) {
+		params = append(params, fmt.Sprintf(`%s="%s"`, key, value))
+	}
+
+	if t.protocol() == ForwardRELP {
+		add("type", "omrelp")
+		add("target", t.Host)
+		add("port", strconv.Itoa(t.port()))
+		add("template", template)
+		if t.TLS != nil {
+			add("tls", "on")
+			if t.TLS.CAFile != "" {
+				add("tls.caCert", t.TLS.CAFile)
+			}
+			if t.TLS.CertFile != "" {
+				add("tls.myCert", t.TLS.CertFile)
+				add("tls.myPrivKey", t.TLS.KeyFile)
+			}
+			switch t.TLS.AuthMode {
+			case "", "x509/name":
+				add("tls.authMode", "name")
+			case "x509/fingerprint":
+				add("tls.authMode", "fingerprint")
+			case "x509/certvalid":
+				add("tls.authMode", "certvalid")
+			}
+			if len(t.TLS.PermittedPeers) > 0 {
+				params = append(params, fmt.Sprintf(`tls.permittedPeer=["%s"]`, strings.Join(t.TLS.PermittedPeers, `","`)))
+			}
+		}
+	} else {
+		add("type", "omfwd")
+		add("target", t.Host)
+		add("port", strconv.Itoa(t.port()))
+		add("protocol", string(t.protocol()))
+		add("template", template)
+		if t.TLS != nil {
+			authMode := t.TLS.AuthMode
+			if authMode == "" {
+				authMode = "x509/name"
+			}
+			add("TCP_Framing", "octet-counted")
+			add("StreamDriver", "gtls")
+			add("StreamDriverMode", "1")
+			add("StreamDriverAuthMode", authMode)
+			if len(t.TLS.PermittedPeers) > 0 {
+				add("StreamDriverPermittedPeers", strings.Join(t.TLS.PermittedPeers, ","))
+			}
+			if t.TLS.CAFile != "" {
+				add("streamDriver.CAFile", t.TLS.CAFile)
+			}
+			if t.TLS.CertFile != "" {
+				add("streamDriver.CertFile", t.TLS.CertFile)
+				add("streamDriver.KeyFile", t.TLS.KeyFile)
+			}
+		}
+	}
+
+	if q := t.Queue; q != nil {
+		add("queue.type", "LinkedList")
+		if q.Size > 0 {
+			add("queue.size", strconv.Itoa(q.Size))
+		}
+		if q.FileName != "" {
+			add("queue.filename", q.FileName)
+		}
+		if q.MaxDiskSpace != "" {
+			size, _ := ParseSize(q.MaxDiskSpace, false)
+			add("queue.maxDiskSpace", size)
+		}
+		if q.SaveOnShutdown {
+			add("queue.saveOnShutdown", "on")
+		}
+		add("action.resumeRetryCount", "-1")
+	}
+
+	return "action(" + strings.Join(params, "\n\t       ") + ")"
+}
+
+const (
+	rsyslogMainConf = "/etc/rsyslog.conf"
+	rsyslogConfDir  = "/etc/rsyslog.d"
+
+	// omrelpModuleFile is the shared fragment loading omrelp for RELP targets,
+	// sorted before the service fragments that use it.
+	omrelpModuleFile = "00-omrelp.conf"
+)
+
+// omrelpLoadRe matches RainerScript and legacy directives loading omrelp.
+var omrelpLoadRe = regexp.MustCompile(`(?m)^\s*(module\s*\(\s*load\s*=\s*"omrelp"|\$ModLoad\s+omrelp\b)`)
+
+// omrelpActionRe matches actions forwarding with omrelp.
+var omrelpActionRe = regexp.MustCompile(`type\s*=\s*"omrelp"`)
+
+// ensureOmrelp writes the shared omrelp module fragment to confDir when the
+// rsyslog configuration uses RELP and neither mainConf nor a fragment in
+// confDir loads omrelp yet, since loading a module twice is an error. It
+// returns the path of the fragment if it was created.
+func ensureOmrelp(c *ServiceConfig, mainConf, confDir, content string) (string, error) {
+	if !omrelpActionRe.MatchString(content) {
+		return "", nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(confDir, "*.conf"))
+	if err != nil {
+		return "", err
+	}
+	for _, path := range append([]string{mainConf}, files...) {
+		data, err := os.ReadFile(path) // #nosec G304
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if omrelpLoadRe.Match(data) {
+			return "", nil
+		}
+	}
+
+	path := filepath.Join(confDir, omrelpModuleFile)
+	conf := "# Loaded for RELP log forwarding, shared by the services using it\n" + `module(load="omrelp")` + "\n"
+	if err := c.writeFile(path, []byte(conf)); err != nil {
+		return "", fmt.Errorf("failed to load omrelp: %w", err)
+	}
+	return path, nil
+}
+
+// removeOmrelp removes the shared omrelp module fragment from confDir once no
+// other fragment forwards with omrelp. It reports whether the fragment was removed.
+func removeOmrelp(c *ServiceConfig, confDir string) (bool, error) {
+	path := filepath.Join(confDir, omrelpModuleFile)
+	if _, err := os.Stat(path); err != nil {
+		return false, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(confDir, "*.conf"))
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		if file == path {
+			continue
+		}
+		data, err := os.ReadFile(file) // #nosec G304
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return false, err
+		}
+		if omrelpActionRe.Match(data) {
+			return false, nil
+		}
+	}
+	return true, c.removeFile(path)
+}
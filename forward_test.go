@@ -0,0 +1,272 @@
+package systemd
+
+import (
+	"context"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRenderForwardAction tests rsyslog action generation for forward targets
+func TestRenderForwardAction(t *testing.T) {
+	t.Run("tcp tls queue", func(t *testing.T) {
+		target := ForwardTarget{
+			Host: "logs.example.com",
+			TLS: &ForwardTLS{
+				CAFile:         "/etc/ssl/ca.pem",
+				PermittedPeers: []string{"logs.example.com"},
+			},
+			Queue: &ForwardQueue{Size: 10000, FileName: "fwd-app", MaxDiskSpace: "1g", SaveOnShutdown: true},
+		}
+		expected := `action(type="omfwd"
+	       target="logs.example.com"
+	       port="6514"
+	       protocol="tcp"
+	       template="RSYSLOG_SyslogProtocol23Format"
+	       TCP_Framing="octet-counted"
+	       StreamDriver="gtls"
+	       StreamDriverMode="1"
+	       StreamDriverAuthMode="x509/name"
+	       StreamDriverPermittedPeers="logs.example.com"
+	       streamDriver.CAFile="/etc/ssl/ca.pem"
+	       queue.type="LinkedList"
+	       queue.size="10000"
+	       queue.filename="fwd-app"
+	       queue.maxDiskSpace="1G"
+	       queue.saveOnShutdown="on"
+	       action.resumeRetryCount="-1")`
+		if got := renderForwardAction(&target); got != expected {
+			t.Errorf("Action mismatch.\nExpected:\n%s\nGot:\n%s", expected, got)
+		}
+	})
+
+	t.Run("udp", func(t *testing.T) {
+		target := ForwardTarget{Protocol: ForwardUDP, Host: "10.0.0.5", Port: 5140, Template: "RSYSLOG_ForwardFormat"}
+		expected := `action(type="omfwd"
+	       target="10.0.0.5"
+	       port="5140"
+	       protocol="udp"
+	       template="RSYSLOG_ForwardFormat")`
+		if got := renderForwardAction(&target); got != expected {
+			t.Errorf("Action mismatch.\nExpected:\n%s\nGot:\n%s", expected, got)
+		}
+	})
+
+	t.Run("relp", func(t *testing.T) {
+		target := ForwardTarget{
+			Protocol: ForwardRELP,
+			Host:     "relp.example.com",
+			TLS:      &ForwardTLS{CAFile: "/ca.pem", CertFile: "/c.pem", KeyFile: "/k.pem", PermittedPeers: []string{"a", "b"}},
+		}
+		got := renderForwardAction(&target)
+		for _, want := range []string{
+			`type="omrelp"`, `port="2514"`, `tls="on"`, `tls.caCert="/ca.pem"`,
+			`tls.myCert="/c.pem"`, `tls.myPrivKey="/k.pem"`, `tls.authMode="name"`, `tls.permittedPeer=["a","b"]`,
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("Expected %s in action:\n%s", want, got)
+			}
+		}
+	})
+}
+
+// TestRenderRsyslogConfForwards tests that forward actions are rendered into stream blocks
+func TestRenderRsyslogConfForwards(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/app", "/var/log/app",
+		WithStreams(map[string]string{"CORE": "core.log", "AUDIT": "audit.log"}),
+		WithForward(ForwardTarget{Host: "all.example.com"}),
+		WithStreamForward("AUDIT", ForwardTarget{Protocol: ForwardRELP, Host: "audit.example.com"}),
+	)
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+
+	conf := renderRsyslogConf(&cfg)
+	if n := strings.Count(conf, `target="all.example.com"`); n != 2 {
+		t.Errorf("Expected global forward in both streams, found %d:\n%s", n, conf)
+	}
+	if n := strings.Count(conf, `target="audit.example.com"`); n != 1 {
+		t.Errorf("Expected stream forward once, found %d:\n%s", n, conf)
+	}
+	if !strings.Contains(rsyslogValidationConf("/tmp", "/tmp/f.conf", true), `module(load="omrelp")`) {
+		t.Error("Expected validation config to load omrelp for RELP targets")
+	}
+}
+
+// TestOmrelpModule tests loading and unloading the shared omrelp fragment
+func TestOmrelpModule(t *testing.T) {
+	dir := t.TempDir()
+	mainConf := filepath.Join(dir, "rsyslog.conf")
+	confDir := filepath.Join(dir, "rsyslog.d")
+	if err := os.Mkdir(confDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mainConf, []byte("module(load=\"imuxsock\")\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := &ServiceConfig{}
+	relp := `action(type="omrelp" target="h")`
+
+	if path, err := ensureOmrelp(c, mainConf, confDir, `action(type="omfwd" target="h")`); err != nil || path != "" {
+		t.Errorf("Expected no module fragment without RELP targets, got %q (%v)", path, err)
+	}
+	path, err := ensureOmrelp(c, mainConf, confDir, relp)
+	if err != nil || path != filepath.Join(confDir, omrelpModuleFile) {
+		t.Fatalf("Expected module fragment, got %q (%v)", path, err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `module(load="omrelp")`) {
+		t.Errorf("Expected omrelp to be loaded, got:\n%s", data)
+	}
+	if again, err := ensureOmrelp(c, mainConf, confDir, relp); err != nil || again != "" {
+		t.Errorf("Expected omrelp not to be loaded twice, got %q (%v)", again, err)
+	}
+
+	// A fragment still forwarding over RELP keeps the module loaded
+	other := filepath.Join(confDir, "other.conf")
+	if err := os.WriteFile(other, []byte(relp), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := removeOmrelp(c, confDir); err != nil || removed {
+		t.Errorf("Expected module kept while in use, got %v (%v)", removed, err)
+	}
+	_ = os.Remove(other)
+	if removed, err := removeOmrelp(c, confDir); err != nil || !removed {
+		t.Errorf("Expected module removed, got %v (%v)", removed, err)
+	}
+
+	// A module loaded by the main configuration is not loaded again
+	if err := os.WriteFile(mainConf, []byte("$ModLoad omrelp\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := ensureOmrelp(c, mainConf, confDir, relp); err != nil || path != "" {
+		t.Errorf("Expected module loaded by rsyslog.conf to be reused, got %q (%v)", path, err)
+	}
+}
+
+// TestValidateForwards tests rejection of invalid forward targets
+func TestValidateForwards(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  ServiceOpt
+	}{
+		{"no host", WithForward(ForwardTarget{})},
+		{"bad protocol", WithForward(ForwardTarget{Protocol: "sctp", Host: "h"})},
+		{"udp tls", WithForward(ForwardTarget{Protocol: ForwardUDP, Host: "h", TLS: &ForwardTLS{}})},
+		{"cert without key", WithForward(ForwardTarget{Host: "h", TLS: &ForwardTLS{CertFile: "/c.pem"}})},
+		{"bad queue size", WithForward(ForwardTarget{Host: "h", Queue: &ForwardQueue{MaxDiskSpace: "lots"}})},
+		{"unknown stream", WithStreamForward("missing", ForwardTarget{Host: "h"})},
+		{"quoted template", WithForward(ForwardTarget{Host: "h", Template: `x" type="omfile`})},
+		{"newline in CA file", WithForward(ForwardTarget{Host: "h", TLS: &ForwardTLS{CAFile: "/ca.pem\n*.* /tmp/x"}})},
+		{"quoted key", WithForward(ForwardTarget{Host: "h", TLS: &ForwardTLS{CertFile: "/c.pem", KeyFile: `/k"`}})},
+		{"quoted peer", WithForward(ForwardTarget{Host: "h", TLS: &ForwardTLS{PermittedPeers: []string{`a"]`}}})},
+		{"comma in peer", WithForward(ForwardTarget{Host: "h", TLS: &ForwardTLS{PermittedPeers: []string{"a,b"}}})},
+		{"backslash in queue file", WithForward(ForwardTarget{Host: "h", Queue: &ForwardQueue{FileName: `q\`}})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewServiceConfig("svc", "svc", "/usr/bin/app", "/var/log/app",
+				WithStream("CORE", "core.log"), tt.opt)
+			if err := cfg.Validate(); err == nil {
+				t.Error("Expected validation error, got nil")
+			}
+		})
+	}
+}
+
+// TestForwardProbe tests reachability checks against loopback listeners
+func TestForwardProbe(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("tcp", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Skipf("Cannot listen on loopback: %v", err)
+		}
+		port := ln.Addr().(*net.TCPAddr).Port
+		target := ForwardTarget{Host: "127.0.0.1", Port: port}
+
+		if err := target.Probe(ctx); err != nil {
+			t.Errorf("Expected listener to be reachable: %v", err)
+		}
+
+		_ = ln.Close()
+		if err := target.Probe(ctx); err == nil {
+			t.Error("Expected closed listener to be unreachable")
+		}
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.NotFoundHandler())
+		defer srv.Close()
+
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+		if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		host, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+		port, _ := strconv.Atoi(portStr)
+
+		target := ForwardTarget{Host: host, Port: port, TLS: &ForwardTLS{CAFile: caFile}}
+		if err := target.Probe(ctx); err != nil {
+			t.Errorf("Expected TLS handshake to succeed: %v", err)
+		}
+
+		untrusted := ForwardTarget{Host: host, Port: port, TLS: &ForwardTLS{}}
+		if err := untrusted.Probe(ctx); err == nil {
+			t.Error("Expected TLS handshake with unknown CA to fail")
+		}
+	})
+}
+
+// TestProbeForwardsConcurrent tests that stalled collectors are probed in parallel
+func TestProbeForwardsConcurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Waits for the probe timeout")
+	}
+
+	var opts []ServiceOpt
+	for i := 0; i < 3; i++ {
+		// Accept connections but never answer the TLS handshake
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Skipf("Cannot listen on loopback: %v", err)
+		}
+		defer ln.Close()
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+			}
+		}()
+		opts = append(opts, WithForward(ForwardTarget{
+			Host: "127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port, TLS: &ForwardTLS{AuthMode: "anon"}}))
+	}
+
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/app", "/var/log/app",
+		append(opts, WithStream("CORE", "core.log"))...)
+	errChan := make(chan error, 10)
+	m := NewManager(&cfg, WithErrorChan(errChan))
+
+	start := time.Now()
+	m.probeForwards()
+	if elapsed := time.Since(start); elapsed >= 2*forwardProbeTimeout {
+		t.Errorf("Expected probes to run concurrently, took %s", elapsed)
+	}
+	if len(errChan) != 3 {
+		t.Errorf("Expected 3 unreachable targets, got %d", len(errChan))
+	}
+}
@@ -0,0 +1,284 @@
+package systemd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrUnknownStream is returned when a record is routed to a stream that is not
+// configured in ServiceConfig.Streams.
+var ErrUnknownStream = errors.New("unknown log stream")
+
+// HandlerOutput selects where a StreamHandler writes records.
+type HandlerOutput int
+
+const (
+	// OutputSyslog sends records to the local syslog socket (/dev/log).
+	OutputSyslog HandlerOutput = iota
+	// OutputStdout writes records to stdout with sd-daemon "<N>" priority
+	// prefixes, for services using StandardOutput=journal (see WithJournal).
+	OutputStdout
+)
+
+// syslogFacilityDaemon is the syslog facility used for service records.
+const syslogFacilityDaemon = 3
+
+// StreamHandlerOptions configures a StreamHandler. The zero value writes to
+// /dev/log at Info level and routes records by their "stream" attribute.
+type StreamHandlerOptions struct {
+	Output        HandlerOutput         // Destination of records
+	Level         slog.Leveler          // Minimum level (defaults to slog.LevelInfo)
+	StreamKey     string                // Attribute naming the stream (defaults to "stream")
+	DefaultStream string                // Stream for records not routed otherwise (empty leaves them untagged)
+	LevelStreams  map[slog.Level]string // Streams for records at or above each level
+	SyslogAddr    string                // Syslog socket path (defaults to /dev/log)
+	Writer        io.Writer             // Destination for OutputStdout (defaults to os.Stdout)
+}
+
+// StreamHandler is a slog.Handler that tags each record with stream=<name> so
+// the rsyslog rules generated for the service route it to the stream's log file.
+//
+// The stream is taken from the record's or logger's stream attribute, then from
+// LevelStreams, then DefaultStream. Streams not present in ServiceConfig.Streams
+// are refused with ErrUnknownStream.
+type StreamHandler struct {
+	shared *handlerShared
+	inner  slog.Handler
+	stream string
+}
+
+// handlerShared holds the state shared by a handler and its derived handlers.
+type handlerShared struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	opts    StreamHandlerOptions
+	streams map[string]string
+	ident   string
+	conn    net.Conn
+	out     io.Writer
+}
+
+// NewStreamHandler creates a StreamHandler for the service described by cfg.
+// Records are identified by the service's ProgramName so the service's rsyslog
+// filter selects them.
+func NewStreamHandler(cfg *ServiceConfig, opts *StreamHandlerOptions) (*StreamHandler, error) {
+	s := &handlerShared{streams: cfg.Streams, ident: programName(cfg)}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.Level == nil {
+		s.opts.Level = slog.LevelInfo
+	}
+	if s.opts.StreamKey == "" {
+		s.opts.StreamKey = "stream"
+	}
+	if s.opts.SyslogAddr == "" {
+		s.opts.SyslogAddr = "/dev/log"
+	}
+
+	if s.opts.DefaultStream != "" {
+		if err := s.checkStream(s.opts.DefaultStream); err != nil {
+			return nil, err
+		}
+	}
+	for _, stream := range s.opts.LevelStreams {
+		if err := s.checkStream(stream); err != nil {
+			return nil, err
+		}
+	}
+
+	switch s.opts.Output {
+	case OutputStdout:
+		s.out = s.opts.Writer
+		if s.out == nil {
+			s.out = os.Stdout
+		}
+	case OutputSyslog:
+		if err := s.dial(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown handler output %d", s.opts.Output)
+	}
+
+	inner := slog.NewTextHandler(&s.buf, &slog.HandlerOptions{
+		Level: s.opts.Level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// The syslog/journal record carries the timestamp
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+
+	return &StreamHandler{shared: s, inner: inner}, nil
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *StreamHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+// Handle formats the record, tags it with its stream and writes it.
+func (h *StreamHandler) Handle(ctx context.Context, r slog.Record) error {
+	s := h.shared
+	stream := h.stream
+
+	// Drop the stream attribute from the record, it becomes the stream=<name> prefix
+	rec := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == s.opts.StreamKey {
+			stream = a.Value.String()
+		} else {
+			rec.AddAttrs(a)
+		}
+		return true
+	})
+
+	if stream == "" {
+		stream = s.levelStream(r.Level)
+	}
+	if stream != "" {
+		if err := s.checkStream(stream); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf.Reset()
+	if err := h.inner.Handle(ctx, rec); err != nil {
+		return err
+	}
+	line := bytes.TrimRight(s.buf.Bytes(), "\n")
+	if stream != "" {
+		line = append([]byte("stream="+stream+" "), line...)
+	}
+	return s.write(r.Level, line)
+}
+
+// WithAttrs returns a handler with the given attributes. A stream attribute
+// routes all records of the derived handler to that stream.
+func (h *StreamHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	var rest []slog.Attr
+	for _, a := range attrs {
+		if a.Key == h.shared.opts.StreamKey {
+			clone.stream = a.Value.String()
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	clone.inner = h.inner.WithAttrs(rest)
+	return &clone
+}
+
+// WithGroup returns a handler that qualifies subsequent attributes with the group name.
+func (h *StreamHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithGroup(name)
+	return &clone
+}
+
+// Close releases the syslog connection. It is shared by all derived handlers.
+func (h *StreamHandler) Close() error {
+	s := h.shared
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// checkStream verifies that the stream is configured for the service.
+func (s *handlerShared) checkStream(stream string) error {
+	if _, ok := s.streams[stream]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownStream, stream)
+	}
+	return nil
+}
+
+// levelStream returns the stream configured for the highest threshold at or below level.
+func (s *handlerShared) levelStream(level slog.Level) string {
+	thresholds := make([]slog.Level, 0, len(s.opts.LevelStreams))
+	for l := range s.opts.LevelStreams {
+		if l <= level {
+			thresholds = append(thresholds, l)
+		}
+	}
+	if len(thresholds) == 0 {
+		return s.opts.DefaultStream
+	}
+	return s.opts.LevelStreams[slices.Max(thresholds)]
+}
+
+// dial connects to the local syslog socket.
+func (s *handlerShared) dial() error {
+	conn, err := net.Dial("unixgram", s.opts.SyslogAddr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog at %s: %w", s.opts.SyslogAddr, err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// write sends a formatted line with the priority derived from level.
+// Callers must hold s.mu.
+func (s *handlerShared) write(level slog.Level, line []byte) error {
+	severity := syslogSeverity(level)
+
+	if s.out != nil {
+		_, err := fmt.Fprintf(s.out, "<%d>%s\n", severity, line)
+		return err
+	}
+
+	msg := fmt.Sprintf("<%d>%s %s[%d]: %s", syslogFacilityDaemon*8+severity,
+		time.Now().Format(time.Stamp), s.ident, os.Getpid(), line)
+
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+	if _, err := s.conn.Write([]byte(msg)); err != nil {
+		// syslog may have been restarted; reconnect once
+		_ = s.conn.Close()
+		s.conn = nil
+		if err := s.dial(); err != nil {
+			return err
+		}
+		_, err = s.conn.Write([]byte(msg))
+		return err
+	}
+	return nil
+}
+
+// syslogSeverity maps a slog level to a syslog severity.
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level < slog.LevelInfo:
+		return 7 // debug
+	case level < slog.LevelWarn:
+		return 6 // info
+	case level < slog.LevelError:
+		return 4 // warning
+	case level == slog.LevelError:
+		return 3 // err
+	default:
+		return 2 // crit
+	}
+}
@@ -0,0 +1,117 @@
+package systemd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStreamHandlerSyslog tests records sent to a local syslog socket
+func TestStreamHandlerSyslog(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "log.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("Cannot create unixgram socket: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "/var/log/myapp",
+		WithStreams(map[string]string{"HTTP": "http.log", "ERROR": "error.log"}))
+
+	h, err := NewStreamHandler(&cfg, &StreamHandlerOptions{
+		SyslogAddr:   addr,
+		LevelStreams: map[slog.Level]string{slog.LevelError: "ERROR"},
+	})
+	if err != nil {
+		t.Fatalf("NewStreamHandler failed: %v", err)
+	}
+	defer func() { _ = h.Close() }()
+
+	logger := slog.New(h)
+	read := func() string {
+		buf := make([]byte, 4096)
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("Failed to read syslog datagram: %v", err)
+		}
+		return string(buf[:n])
+	}
+
+	logger.With("stream", "HTTP").Info("request", "path", "/")
+	msg := read()
+	prefix := "<30>"
+	suffix := fmt.Sprintf("myapp[%d]: stream=HTTP level=INFO msg=request path=/", os.Getpid())
+	if !strings.HasPrefix(msg, prefix) || !strings.HasSuffix(msg, suffix) {
+		t.Errorf("Unexpected syslog message %q", msg)
+	}
+
+	logger.Error("boom")
+	if msg = read(); !strings.HasPrefix(msg, "<27>") || !strings.HasSuffix(msg, "stream=ERROR level=ERROR msg=boom") {
+		t.Errorf("Expected error record routed to ERROR stream, got %q", msg)
+	}
+
+	logger.Warn("untagged")
+	if msg = read(); !strings.HasPrefix(msg, "<28>") || strings.Contains(msg, "stream=") {
+		t.Errorf("Expected untagged warning, got %q", msg)
+	}
+}
+
+// TestStreamHandlerStdout tests journal-friendly stdout output
+func TestStreamHandlerStdout(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "/var/log/myapp",
+		WithStream("CORE", "core.log"))
+
+	var out bytes.Buffer
+	h, err := NewStreamHandler(&cfg, &StreamHandlerOptions{
+		Output:        OutputStdout,
+		Writer:        &out,
+		Level:         slog.LevelDebug,
+		DefaultStream: "CORE",
+	})
+	if err != nil {
+		t.Fatalf("NewStreamHandler failed: %v", err)
+	}
+
+	logger := slog.New(h).WithGroup("req")
+	logger.Debug("hello", "id", 7)
+
+	expected := "<7>stream=CORE level=DEBUG msg=hello req.id=7\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+// TestStreamHandlerUnknownStream tests rejection of unconfigured streams
+func TestStreamHandlerUnknownStream(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "/var/log/myapp",
+		WithStream("CORE", "core.log"))
+
+	_, err := NewStreamHandler(&cfg, &StreamHandlerOptions{Output: OutputStdout, DefaultStream: "NOPE"})
+	if !errors.Is(err, ErrUnknownStream) {
+		t.Errorf("Expected ErrUnknownStream for default stream, got %v", err)
+	}
+
+	var out bytes.Buffer
+	h, err := NewStreamHandler(&cfg, &StreamHandlerOptions{Output: OutputStdout, Writer: &out})
+	if err != nil {
+		t.Fatalf("NewStreamHandler failed: %v", err)
+	}
+
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0)
+	r.AddAttrs(slog.String("stream", "NOPE"))
+	if err := h.Handle(context.Background(), r); !errors.Is(err, ErrUnknownStream) {
+		t.Errorf("Expected ErrUnknownStream for record stream, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing written for unknown stream, got %q", out.String())
+	}
+}
@@ -0,0 +1,147 @@
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JobResult is the result systemd reports for a finished job.
+type JobResult string
+
+// Job results as reported by systemd.
+const (
+	JobDone       JobResult = "done"       // Job completed successfully
+	JobCanceled   JobResult = "canceled"   // Job was canceled before it finished
+	JobTimeout    JobResult = "timeout"    // Job timed out
+	JobFailed     JobResult = "failed"     // Job failed, e.g. the service exited with an error
+	JobDependency JobResult = "dependency" // A job this job depended on failed
+	JobSkipped    JobResult = "skipped"    // Job did not apply to the unit's current state
+)
+
+// JobStatus is the outcome of a start, stop or restart operation.
+type JobStatus struct {
+	Unit           string    // Unit the job ran on
+	Operation      string    // "start", "stop" or "restart"
+	Result         JobResult // Job result
+	UnitResult     string    // Unit Result property, e.g. "success", "exit-code", "timeout"
+	ExecMainStatus int       // Exit status or signal of the main process
+}
+
+// JobError is returned when a job does not finish successfully. Use errors.As
+// to inspect the job and unit results.
+type JobError struct {
+	JobStatus
+	Err error // Underlying error, e.g. the failed systemctl command (may be nil)
+}
+
+// Error implements the error interface.
+func (e *JobError) Error() string {
+	msg := fmt.Sprintf("%s %s: job %s", e.Operation, e.Unit, e.Result)
+	if e.UnitResult != "" && e.UnitResult != "success" {
+		msg += fmt.Sprintf(" (result %s, status %d)", e.UnitResult, e.ExecMainStatus)
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *JobError) Unwrap() error {
+	return e.Err
+}
+
+// systemctlJobResults maps the messages systemctl prints for failed jobs to job
+// results, in the order they are matched.
+var systemctlJobResults = []struct {
+	text   string
+	result JobResult
+}{
+	{"A dependency job for", JobDependency},
+	{"failed because a timeout was exceeded", JobTimeout},
+	{" canceled.", JobCanceled},
+	{"Job for ", JobFailed},
+}
+
+// systemctlJobResult derives the job result from a failed systemctl command.
+// It returns an empty result if the command failed before a job ran.
+func systemctlJobResult(err error) JobResult {
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.ExitCode <= 0 {
+		return ""
+	}
+	for _, r := range systemctlJobResults {
+		if strings.Contains(cmdErr.Stderr, r.text) {
+			return r.result
+		}
+	}
+	return ""
+}
+
+// Start starts the service and waits for the start job to finish.
+// A job that does not finish successfully is returned as *JobError.
+func (m *Manager) Start() (*JobStatus, error) {
+	return m.lifecycle("start")
+}
+
+// Stop stops the service and waits for the stop job to finish.
+// A job that does not finish successfully is returned as *JobError.
+func (m *Manager) Stop() (*JobStatus, error) {
+	return m.lifecycle("stop")
+}
+
+// Restart restarts the service and waits for the restart job to finish.
+// A job that does not finish successfully is returned as *JobError.
+func (m *Manager) Restart() (*JobStatus, error) {
+	return m.lifecycle("restart")
+}
+
+// lifecycle opens the backend and runs a job on the service.
+func (m *Manager) lifecycle(op string) (*JobStatus, error) {
+	var b backend
+	defer func() {
+		if b != nil {
+			_ = b.close()
+		}
+	}()
+
+	var status *JobStatus
+	err := m.runSteps([]step{
+		{StepConnect, "", func() (err error) {
+			b, err = m.openBackend()
+			return err
+		}},
+		{Step(op), "", func() (err error) {
+			status, err = m.runJob(b, op)
+			return err
+		}},
+	})
+	if err != nil {
+		return status, err
+	}
+	m.infof("Service %s: job %s", op, status.Result)
+	return status, nil
+}
+
+// runJob runs a start, stop or restart job on the service and collects the unit's
+// result. Jobs finishing as done or skipped succeed.
+func (m *Manager) runJob(b backend, op string) (*JobStatus, error) {
+	unit := m.cfg.ServiceName
+	result, err := b.job(op, unit)
+	if result == "" {
+		if err == nil {
+			err = fmt.Errorf("%s job of %s finished without a result", op, unit)
+		}
+		return nil, err
+	}
+
+	status := &JobStatus{Unit: unit, Operation: op, Result: result}
+	if props, err := b.properties(unit, "Result", "ExecMainStatus"); err == nil {
+		status.UnitResult = props["Result"]
+		status.ExecMainStatus, _ = strconv.Atoi(props["ExecMainStatus"])
+	}
+
+	if result != JobDone && result != JobSkipped {
+		return status, &JobError{JobStatus: *status, Err: err}
+	}
+	return status, nil
+}
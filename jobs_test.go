@@ -0,0 +1,124 @@
+package systemd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeSystemctlJob returns a fake systemctl printing msg and failing when msg is
+// set, and reporting the given unit properties.
+func fakeSystemctlJob(msg string) string {
+	return `case "$1" in
+show) printf 'Result=exit-code\nExecMainStatus=3\n'; exit 0 ;;
+esac
+[ -z "` + msg + `" ] && exit 0
+echo "` + msg + `" >&2
+exit 1`
+}
+
+// TestSystemctlJobResults tests mapping systemctl failures to job results
+func TestSystemctlJobResults(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want JobResult
+	}{
+		{"Job for app.service failed because a timeout was exceeded.", JobTimeout},
+		{"A dependency job for app.service failed. See 'journalctl -xe' for details.", JobDependency},
+		{"Job for app.service canceled.", JobCanceled},
+		{"Job for app.service failed because the control process exited with error code.", JobFailed},
+	}
+	for _, tt := range tests {
+		fakeCommands(t, map[string]string{"systemctl": fakeSystemctlJob(tt.msg)})
+
+		m := NewManager(&ServiceConfig{ServiceName: "app.service"})
+		status, err := m.Restart()
+
+		var jobErr *JobError
+		if !errors.As(err, &jobErr) {
+			t.Errorf("%q: expected JobError, got %v", tt.msg, err)
+			continue
+		}
+		if jobErr.Result != tt.want || jobErr.Operation != "restart" || jobErr.Unit != "app.service" {
+			t.Errorf("%q: expected restart job %s, got %+v", tt.msg, tt.want, jobErr.JobStatus)
+		}
+		if jobErr.UnitResult != "exit-code" || jobErr.ExecMainStatus != 3 {
+			t.Errorf("Expected unit result exit-code with status 3, got %q and %d", jobErr.UnitResult, jobErr.ExecMainStatus)
+		}
+		if status == nil || status.Result != tt.want {
+			t.Errorf("Expected status with result %s, got %+v", tt.want, status)
+		}
+		if !strings.Contains(err.Error(), "job "+string(tt.want)) || !strings.Contains(err.Error(), "result exit-code, status 3") {
+			t.Errorf("Unexpected error message: %v", err)
+		}
+	}
+}
+
+// TestManagerStartStop tests successful lifecycle jobs through systemctl
+func TestManagerStartStop(t *testing.T) {
+	dir := fakeCommands(t, map[string]string{"systemctl": fakeSystemctlJob("")})
+
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"})
+	status, err := m.Start()
+	if err != nil || status.Result != JobDone {
+		t.Errorf("Expected start job done, got %+v (%v)", status, err)
+	}
+	if _, err := m.Stop(); err != nil {
+		t.Errorf("Stop failed: %v", err)
+	}
+
+	calls := fakeCalls(t, dir)
+	for _, want := range []string{"systemctl start app.service", "systemctl stop app.service"} {
+		if !strings.Contains(calls, want) {
+			t.Errorf("Expected %q in calls:\n%s", want, calls)
+		}
+	}
+}
+
+// TestManagerJobCommandError tests that a failure before any job ran is not a JobError
+func TestManagerJobCommandError(t *testing.T) {
+	fakeCommands(t, map[string]string{"systemctl": `echo "Unit app.service not found." >&2; exit 5`})
+
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"})
+	status, err := m.Start()
+	var jobErr *JobError
+	if err == nil || errors.As(err, &jobErr) || status != nil {
+		t.Errorf("Expected plain command error, got %v (%+v)", err, status)
+	}
+}
+
+// TestManagerJobDBus tests job and unit results reported over D-Bus
+func TestManagerJobDBus(t *testing.T) {
+	addr := startDBusDaemon(t)
+	fake := startFakeSystemd(t, addr)
+	fake.mu.Lock()
+	fake.jobResult = "failed"
+	fake.mu.Unlock()
+
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"}, WithBusAddress(addr))
+	_, err := m.Start()
+
+	var jobErr *JobError
+	if !errors.As(err, &jobErr) {
+		t.Fatalf("Expected JobError, got %v", err)
+	}
+	want := JobStatus{Unit: "app.service", Operation: "start", Result: JobFailed, UnitResult: "exit-code", ExecMainStatus: 2}
+	if jobErr.JobStatus != want {
+		t.Errorf("Expected %+v, got %+v", want, jobErr.JobStatus)
+	}
+}
+
+// TestManagerJobDBusNoResult tests that a job removed without a result is an error
+func TestManagerJobDBusNoResult(t *testing.T) {
+	addr := startDBusDaemon(t)
+	fake := startFakeSystemd(t, addr)
+	fake.mu.Lock()
+	fake.jobResult = ""
+	fake.mu.Unlock()
+
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"}, WithBusAddress(addr))
+	status, err := m.Start()
+	if err == nil || status != nil {
+		t.Errorf("Expected error without job result, got %+v (%v)", status, err)
+	}
+}
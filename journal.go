@@ -0,0 +1,252 @@
+package systemd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultJournalSocket is the path of journald's native protocol socket.
+const DefaultJournalSocket = "/run/systemd/journal/socket"
+
+// journalFieldRe matches field names accepted by journald: uppercase letters,
+// digits and underscores, not starting with a digit or underscore.
+var journalFieldRe = regexp.MustCompile(`^[A-Z][A-Z0-9_]{0,63}$`)
+
+// JournalWriter sends entries to journald over its native protocol, preserving
+// structured fields. Entries too large for a datagram are passed as a sealed
+// memfd. A JournalWriter is safe for concurrent use.
+type JournalWriter struct {
+	Identifier string            // SYSLOG_IDENTIFIER of every entry (empty to omit)
+	Addr       string            // Journal socket path (defaults to DefaultJournalSocket)
+	Fields     map[string]string // Fields added to every entry
+
+	mu   sync.Mutex
+	conn *net.UnixConn
+}
+
+// NewJournalWriter creates a JournalWriter using the given syslog identifier.
+func NewJournalWriter(identifier string) *JournalWriter {
+	return &JournalWriter{Identifier: identifier}
+}
+
+// Write sends p as the MESSAGE of an informational entry, making the writer
+// usable wherever an io.Writer is expected. A trailing newline is removed.
+func (w *JournalWriter) Write(p []byte) (int, error) {
+	if err := w.Send(6, string(bytes.TrimSuffix(p, []byte("\n"))), nil); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Send writes an entry with the given syslog priority (0-7), message and extra
+// fields. Field names must be uppercase journal field names such as "REQUEST_ID".
+func (w *JournalWriter) Send(priority int, message string, fields map[string]string) error {
+	if priority < 0 || priority > 7 {
+		return fmt.Errorf("invalid journal priority %d", priority)
+	}
+
+	var buf bytes.Buffer
+	appendJournalField(&buf, "MESSAGE", message)
+	appendJournalField(&buf, "PRIORITY", strconv.Itoa(priority))
+	if w.Identifier != "" {
+		appendJournalField(&buf, "SYSLOG_IDENTIFIER", w.Identifier)
+	}
+	for _, extra := range []map[string]string{w.Fields, fields} {
+		keys := make([]string, 0, len(extra))
+		for k := range extra {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			if !journalFieldRe.MatchString(k) {
+				return fmt.Errorf("invalid journal field name %q", k)
+			}
+			appendJournalField(&buf, k, extra[k])
+		}
+	}
+
+	return w.send(buf.Bytes())
+}
+
+// Close closes the connection to the journal socket.
+func (w *JournalWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// send writes a serialized entry, falling back to fd passing for large entries.
+func (w *JournalWriter) send(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		addr := w.Addr
+		if addr == "" {
+			addr = DefaultJournalSocket
+		}
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+		if err != nil {
+			return fmt.Errorf("failed to connect to journal at %s: %w", addr, err)
+		}
+		w.conn = conn
+	}
+
+	_, err := w.conn.Write(data)
+	if err != nil && journalTooLarge(err) {
+		return sendJournalLarge(w.conn, data)
+	}
+	return err
+}
+
+// appendJournalField serializes a field in journald's native format. Values
+// containing newlines use the length-prefixed binary form.
+func appendJournalField(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// JournalHandlerOptions configures a JournalHandler.
+type JournalHandlerOptions struct {
+	Level slog.Leveler // Minimum level (defaults to slog.LevelInfo)
+}
+
+// JournalHandler is a slog.Handler writing records to journald with structured
+// fields: MESSAGE, PRIORITY, SYSLOG_IDENTIFIER, CODE_FILE/CODE_LINE/CODE_FUNC when
+// the record has a source location, and one field per attribute. Attribute keys
+// are converted to journal field names (e.g. "request.id" becomes "REQUEST_ID").
+type JournalHandler struct {
+	w      *JournalWriter
+	level  slog.Leveler
+	prefix string
+	fields map[string]string
+}
+
+// NewJournalHandler creates a JournalHandler writing through w.
+func NewJournalHandler(w *JournalWriter, opts *JournalHandlerOptions) *JournalHandler {
+	h := &JournalHandler{w: w, level: slog.LevelInfo, fields: map[string]string{}}
+	if opts != nil && opts.Level != nil {
+		h.level = opts.Level
+	}
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *JournalHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle sends the record to the journal.
+func (h *JournalHandler) Handle(_ context.Context, r slog.Record) error {
+	fields := make(map[string]string, len(h.fields)+r.NumAttrs()+3)
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addJournalAttr(fields, h.prefix, a)
+		return true
+	})
+
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		if frame.File != "" {
+			fields["CODE_FILE"] = frame.File
+			fields["CODE_LINE"] = strconv.Itoa(frame.Line)
+			fields["CODE_FUNC"] = frame.Function
+		}
+	}
+
+	return h.w.Send(syslogSeverity(r.Level), r.Message, fields)
+}
+
+// WithAttrs returns a handler adding the attributes to every entry.
+func (h *JournalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.fields = make(map[string]string, len(h.fields)+len(attrs))
+	for k, v := range h.fields {
+		clone.fields[k] = v
+	}
+	for _, a := range attrs {
+		addJournalAttr(clone.fields, h.prefix, a)
+	}
+	return &clone
+}
+
+// WithGroup returns a handler prefixing subsequent field names with the group name.
+func (h *JournalHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "_"
+	return &clone
+}
+
+// addJournalAttr flattens an attribute into journal fields.
+func addJournalAttr(fields map[string]string, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix += a.Key + "_"
+		}
+		for _, ga := range a.Value.Group() {
+			addJournalAttr(fields, groupPrefix, ga)
+		}
+		return
+	}
+	if name := journalFieldName(prefix + a.Key); name != "" {
+		fields[name] = a.Value.String()
+	}
+}
+
+// journalFieldName converts an attribute key into a valid journal field name,
+// or returns "" if nothing usable remains. Fields reserved by the handler are
+// prefixed so attributes cannot override them.
+func journalFieldName(key string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(key) {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	name := strings.TrimLeft(b.String(), "_0123456789")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	switch name {
+	case "MESSAGE", "PRIORITY", "SYSLOG_IDENTIFIER", "CODE_FILE", "CODE_LINE", "CODE_FUNC":
+		return "ATTR_" + name
+	}
+	return name
+}
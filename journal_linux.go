@@ -0,0 +1,110 @@
+//go:build linux
+
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// memfd and file sealing constants from linux/memfd.h and linux/fcntl.h.
+const (
+	mfdCloexec       = 0x1
+	mfdAllowSealing  = 0x2
+	fAddSeals        = 1033
+	sealAllForMemfd  = 0x1 | 0x2 | 0x4 | 0x8 // F_SEAL_SEAL | F_SEAL_SHRINK | F_SEAL_GROW | F_SEAL_WRITE
+	journalShmPrefix = "/dev/shm"
+)
+
+// memfdCreateSyscall holds the memfd_create syscall number per architecture,
+// which the frozen syscall package does not define.
+var memfdCreateSyscall = map[string]uintptr{
+	"386":     356,
+	"amd64":   319,
+	"arm":     385,
+	"arm64":   279,
+	"loong64": 279,
+	"ppc64":   360,
+	"ppc64le": 360,
+	"riscv64": 279,
+	"s390x":   350,
+}
+
+// journalTooLarge reports whether a datagram send failed because of its size.
+func journalTooLarge(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// sendJournalLarge passes an entry too large for a datagram to journald as a
+// sealed memfd. Kernels without memfd fall back to an unlinked file in /dev/shm.
+func sendJournalLarge(conn *net.UnixConn, data []byte) error {
+	f, err := journalMemfd(data)
+	if err != nil {
+		if f, err = journalTempFile(data); err != nil {
+			return fmt.Errorf("failed to pass large journal entry: %w", err)
+		}
+	}
+	defer func() { _ = f.Close() }()
+
+	// WriteMsgUnix refuses connected datagram sockets, send on the raw descriptor
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	rights := syscall.UnixRights(int(f.Fd()))
+	var sendErr error
+	if err := raw.Write(func(fd uintptr) bool {
+		sendErr = syscall.Sendmsg(int(fd), nil, rights, nil, 0)
+		return sendErr != syscall.EAGAIN
+	}); err != nil {
+		return err
+	}
+	return sendErr
+}
+
+// journalMemfd stores data in a sealed anonymous memory file.
+func journalMemfd(data []byte) (*os.File, error) {
+	trap, ok := memfdCreateSyscall[runtime.GOARCH]
+	if !ok {
+		return nil, syscall.ENOSYS
+	}
+	name, err := syscall.BytePtrFromString("journal-entry")
+	if err != nil {
+		return nil, err
+	}
+	fd, _, errno := syscall.Syscall(trap, uintptr(unsafe.Pointer(name)),
+		mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	f := os.NewFile(fd, "journal-entry")
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, fAddSeals, sealAllForMemfd); errno != 0 {
+		_ = f.Close()
+		return nil, errno
+	}
+	return f, nil
+}
+
+// journalTempFile stores data in an unlinked temporary file.
+func journalTempFile(data []byte) (*os.File, error) {
+	f, err := os.CreateTemp(journalShmPrefix, "journal-")
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,53 @@
+//go:build linux
+
+package systemd
+
+import (
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestJournalWriterLargeEntry tests entries passed as a file descriptor
+func TestJournalWriterLargeEntry(t *testing.T) {
+	conn, addr := listenJournal(t)
+	w := NewJournalWriter("myapp")
+	w.Addr = addr
+	defer func() { _ = w.Close() }()
+
+	message := strings.Repeat("x", 4<<20)
+	if err := w.Send(6, message, nil); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, oobn, _, _, err := conn.ReadMsgUnix(nil, oob)
+	if err != nil {
+		t.Fatalf("Failed to read journal message: %v", err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("Expected one control message, got %d (%v)", len(msgs), err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("Expected one file descriptor, got %d (%v)", len(fds), err)
+	}
+
+	f := os.NewFile(uintptr(fds[0]), "journal-entry")
+	defer func() { _ = f.Close() }()
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("Failed to read passed file: %v", err)
+	}
+	if fields := parseJournalEntry(t, data); fields["MESSAGE"] != message {
+		t.Errorf("Expected %d byte message, got %d bytes", len(message), len(fields["MESSAGE"]))
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package systemd
+
+import (
+	"errors"
+	"net"
+)
+
+// journalTooLarge reports whether a datagram send failed because of its size.
+func journalTooLarge(error) bool {
+	return false
+}
+
+// sendJournalLarge is only supported on Linux, where journald runs.
+func sendJournalLarge(*net.UnixConn, []byte) error {
+	return errors.New("large journal entries are only supported on Linux")
+}
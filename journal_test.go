@@ -0,0 +1,164 @@
+package systemd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenJournal creates a unixgram socket standing in for journald.
+func listenJournal(t *testing.T) (*net.UnixConn, string) {
+	t.Helper()
+	addr := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("Cannot create unixgram socket: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, addr
+}
+
+// parseJournalEntry decodes an entry in journald's native format.
+func parseJournalEntry(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	fields := map[string]string{}
+	for len(data) > 0 {
+		nl := bytes.IndexByte(data, '\n')
+		if nl < 0 {
+			t.Fatalf("Unterminated journal field %q", data)
+		}
+		line := data[:nl]
+		if eq := bytes.IndexByte(line, '='); eq >= 0 {
+			fields[string(line[:eq])] = string(line[eq+1:])
+			data = data[nl+1:]
+			continue
+		}
+		data = data[nl+1:]
+		size := binary.LittleEndian.Uint64(data[:8])
+		fields[string(line)] = string(data[8 : 8+size])
+		data = data[8+size+1:]
+	}
+	return fields
+}
+
+// readJournalEntry reads one datagram from the socket and decodes it.
+func readJournalEntry(t *testing.T, conn *net.UnixConn) map[string]string {
+	t.Helper()
+	buf := make([]byte, 65536)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read journal datagram: %v", err)
+	}
+	return parseJournalEntry(t, buf[:n])
+}
+
+// TestJournalWriterSend tests entries serialized in the native protocol
+func TestJournalWriterSend(t *testing.T) {
+	conn, addr := listenJournal(t)
+
+	w := NewJournalWriter("myapp")
+	w.Addr = addr
+	w.Fields = map[string]string{"VERSION": "1.2"}
+	defer func() { _ = w.Close() }()
+
+	if err := w.Send(3, "line one\nline two", map[string]string{"REQUEST_ID": "42"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	fields := readJournalEntry(t, conn)
+	expected := map[string]string{
+		"MESSAGE":           "line one\nline two",
+		"PRIORITY":          "3",
+		"SYSLOG_IDENTIFIER": "myapp",
+		"VERSION":           "1.2",
+		"REQUEST_ID":        "42",
+	}
+	for k, v := range expected {
+		if fields[k] != v {
+			t.Errorf("Expected %s=%q, got %q", k, v, fields[k])
+		}
+	}
+
+	if _, err := w.Write([]byte("plain\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if fields = readJournalEntry(t, conn); fields["MESSAGE"] != "plain" || fields["PRIORITY"] != "6" {
+		t.Errorf("Expected informational entry 'plain', got %v", fields)
+	}
+}
+
+// TestJournalWriterInvalid tests rejected priorities and field names
+func TestJournalWriterInvalid(t *testing.T) {
+	_, addr := listenJournal(t)
+	w := NewJournalWriter("myapp")
+	w.Addr = addr
+	defer func() { _ = w.Close() }()
+
+	if err := w.Send(8, "msg", nil); err == nil {
+		t.Error("Expected error for priority 8")
+	}
+	for _, name := range []string{"lower", "_PRIVATE", "1ST", "WITH-DASH"} {
+		if err := w.Send(6, "msg", map[string]string{name: "x"}); err == nil {
+			t.Errorf("Expected error for field name %q", name)
+		}
+	}
+}
+
+// TestJournalHandler tests slog records converted to journal fields
+func TestJournalHandler(t *testing.T) {
+	conn, addr := listenJournal(t)
+	w := NewJournalWriter("myapp")
+	w.Addr = addr
+	defer func() { _ = w.Close() }()
+
+	logger := slog.New(NewJournalHandler(w, &JournalHandlerOptions{Level: slog.LevelDebug}))
+	logger.With("request.id", "abc").WithGroup("db").Warn("slow query",
+		"table", "users", slog.Group("timing", "ms", 120), "message", "shadowed")
+
+	fields := readJournalEntry(t, conn)
+	expected := map[string]string{
+		"MESSAGE":           "slow query",
+		"PRIORITY":          "4",
+		"REQUEST_ID":        "abc",
+		"DB_TABLE":          "users",
+		"DB_TIMING_MS":      "120",
+		"DB_MESSAGE":        "shadowed",
+		"SYSLOG_IDENTIFIER": "myapp",
+	}
+	for k, v := range expected {
+		if fields[k] != v {
+			t.Errorf("Expected %s=%q, got %q", k, v, fields[k])
+		}
+	}
+	if !strings.HasSuffix(fields["CODE_FILE"], "journal_test.go") || fields["CODE_LINE"] == "" {
+		t.Errorf("Expected source location fields, got %v", fields)
+	}
+
+	logger.Debug("debug", "message", "attr")
+	if fields = readJournalEntry(t, conn); fields["PRIORITY"] != "7" || fields["ATTR_MESSAGE"] != "attr" {
+		t.Errorf("Expected debug entry with ATTR_MESSAGE, got %v", fields)
+	}
+}
+
+// TestJournalFieldName tests attribute key conversion
+func TestJournalFieldName(t *testing.T) {
+	tests := map[string]string{
+		"user":        "USER",
+		"http.status": "HTTP_STATUS",
+		"_private":    "PRIVATE",
+		"1st":         "ST",
+		"priority":    "ATTR_PRIORITY",
+		"---":         "",
+	}
+	for key, expected := range tests {
+		if got := journalFieldName(key); got != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, key, got)
+		}
+	}
+}
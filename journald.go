@@ -0,0 +1,202 @@
+package systemd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// journaldConfDir is the directory holding journald namespace configurations.
+const journaldConfDir = "/etc/systemd"
+
+var (
+	namespaceRe   = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]{0,63}$`)
+	syslogLevelRe = regexp.MustCompile(`^(emerg|alert|crit|err|warning|notice|info|debug|[0-7])$`)
+)
+
+// JournalNamespace describes an isolated journal for the service (LogNamespace=)
+// and the retention settings of its journald instance.
+type JournalNamespace struct {
+	Name           string        // Namespace name
+	Storage        string        // "persistent", "volatile", "auto" or "none" (journald default when empty)
+	SystemMaxUse   string        // Maximum disk space used by the journal, e.g. "1G"
+	SystemKeepFree string        // Disk space journald leaves free, e.g. "2G"
+	MaxFileSize    string        // Maximum size of a single journal file, e.g. "128M"
+	MaxRetention   time.Duration // Entries older than this are removed (0 keeps them)
+}
+
+// validate checks the namespace settings.
+func (ns *JournalNamespace) validate() error {
+	if !namespaceRe.MatchString(ns.Name) {
+		return fmt.Errorf("invalid namespace name %q", ns.Name)
+	}
+	switch ns.Storage {
+	case "", "persistent", "volatile", "auto", "none":
+	default:
+		return fmt.Errorf("invalid storage %q", ns.Storage)
+	}
+	for key, size := range map[string]string{
+		"SystemMaxUse":      ns.SystemMaxUse,
+		"SystemKeepFree":    ns.SystemKeepFree,
+		"SystemMaxFileSize": ns.MaxFileSize,
+	} {
+		if size == "" {
+			continue
+		}
+		if _, err := ParseSize(size, false); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if ns.MaxRetention < 0 || ns.MaxRetention%time.Second != 0 {
+		return fmt.Errorf("invalid retention %s, must be whole seconds", ns.MaxRetention)
+	}
+	return nil
+}
+
+// WithLogNamespace runs the service with its own journal namespace (LogNamespace=).
+// Install writes /etc/systemd/journald@<name>.conf with the retention settings;
+// the entries are read with journalctl --namespace=<name>.
+func WithLogNamespace(ns JournalNamespace) ServiceOpt {
+	return func(c *ServiceConfig) {
+		if err := ns.validate(); err != nil {
+			c.invalid("LogNamespace", err)
+			return
+		}
+		c.LogNamespace = &ns
+		c.ServiceLines = append(c.ServiceLines, "LogNamespace="+ns.Name)
+	}
+}
+
+// WithLogLevelMax drops messages less severe than level (LogLevelMax=). The level
+// is a syslog level name such as "info" or a number from 0 to 7.
+func WithLogLevelMax(level string) ServiceOpt {
+	return func(c *ServiceConfig) {
+		if !syslogLevelRe.MatchString(level) {
+			c.invalid("LogLevelMax", fmt.Errorf("invalid syslog level %q", level))
+			return
+		}
+		c.ServiceLines = append(c.ServiceLines, "LogLevelMax="+level)
+	}
+}
+
+// WithLogRateLimit allows at most burst messages per interval from the service
+// (LogRateLimitIntervalSec=, LogRateLimitBurst=). A zero interval disables rate limiting.
+func WithLogRateLimit(interval time.Duration, burst int) ServiceOpt {
+	return func(c *ServiceConfig) {
+		if interval < 0 || interval%time.Millisecond != 0 {
+			c.invalid("LogRateLimitIntervalSec", fmt.Errorf("invalid interval %s", interval))
+			return
+		}
+		if burst < 0 {
+			c.invalid("LogRateLimitBurst", fmt.Errorf("invalid burst %d", burst))
+			return
+		}
+		c.ServiceLines = append(c.ServiceLines,
+			fmt.Sprintf("LogRateLimitIntervalSec=%dms", interval.Milliseconds()),
+			fmt.Sprintf("LogRateLimitBurst=%d", burst))
+	}
+}
+
+// WithLogExtraFields adds journal fields to every entry of the service (LogExtraFields=).
+// Field names must be uppercase journal field names such as "TEAM".
+func WithLogExtraFields(fields map[string]string) ServiceOpt {
+	return func(c *ServiceConfig) {
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+
+		for _, k := range keys {
+			v := fields[k]
+			if !journalFieldRe.MatchString(k) {
+				c.invalid("LogExtraFields", fmt.Errorf("invalid journal field name %q", k))
+				continue
+			}
+			if strings.ContainsAny(v, "\n\"\\") {
+				c.invalid("LogExtraFields", fmt.Errorf("invalid value %q for field %s", v, k))
+				continue
+			}
+			c.ServiceLines = append(c.ServiceLines, fmt.Sprintf("LogExtraFields=\"%s=%s\"", k, v))
+		}
+	}
+}
+
+// WithSyslogIdentifier sets the identifier of the service's stdout/stderr entries
+// (SyslogIdentifier=). It also becomes the ProgramName used by the rsyslog rules.
+func WithSyslogIdentifier(id string) ServiceOpt {
+	return func(c *ServiceConfig) {
+		if id == "" || strings.ContainsAny(id, " \t\n") {
+			c.invalid("SyslogIdentifier", fmt.Errorf("invalid identifier %q", id))
+			return
+		}
+		c.ProgramName = id
+		c.ServiceLines = append(c.ServiceLines, "SyslogIdentifier="+id)
+	}
+}
+
+// journaldNamespacePath returns the journald configuration path of a namespace.
+func journaldNamespacePath(ns string) string {
+	return filepath.Join(journaldConfDir, fmt.Sprintf("journald@%s.conf", ns))
+}
+
+// renderJournaldConf generates the journald configuration of the service's namespace.
+func renderJournaldConf(c *ServiceConfig) string {
+	ns := c.LogNamespace
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Journal namespace %s of %s\n[Journal]\n", ns.Name, c.ServiceName)
+	if ns.Storage != "" {
+		fmt.Fprintf(&b, "Storage=%s\n", ns.Storage)
+	}
+	if ns.SystemMaxUse != "" {
+		fmt.Fprintf(&b, "SystemMaxUse=%s\n", ns.SystemMaxUse)
+	}
+	if ns.SystemKeepFree != "" {
+		fmt.Fprintf(&b, "SystemKeepFree=%s\n", ns.SystemKeepFree)
+	}
+	if ns.MaxFileSize != "" {
+		fmt.Fprintf(&b, "SystemMaxFileSize=%s\n", ns.MaxFileSize)
+	}
+	if ns.MaxRetention > 0 {
+		fmt.Fprintf(&b, "MaxRetentionSec=%ds\n", int64(ns.MaxRetention/time.Second))
+	}
+	return b.String()
+}
+
+// writeJournaldConf writes the namespace configuration and restarts its journald
+// instance if it is already running, so the settings apply.
+func writeJournaldConf(c *ServiceConfig) error {
+	path := journaldNamespacePath(c.LogNamespace.Name)
+	if err := c.writeFile(path, []byte(renderJournaldConf(c))); err != nil {
+		return fmt.Errorf("failed to write journald configuration: %w", err)
+	}
+	return c.execCommand("systemctl", "try-restart", fmt.Sprintf("systemd-journald@%s.service", c.LogNamespace.Name))
+}
+
+// removeJournaldConf removes the namespace configuration when no remaining unit
+// file uses the namespace. Failures are reported on the error channel and otherwise ignored.
+func (m *Manager) removeJournaldConf() {
+	c := m.cfg
+	name := c.LogNamespace.Name
+
+	inUse, err := directiveInUse(filepath.Dir(c.SystemdFile), "LogNamespace", name)
+	if err != nil {
+		m.error(fmt.Errorf("failed to check references to journal namespace %s: %w", name, err))
+		return
+	}
+	if inUse {
+		m.infof("Journal namespace %s still in use, keeping its configuration", name)
+		return
+	}
+
+	path := journaldNamespacePath(name)
+	if err := c.removeFile(path); err != nil && !os.IsNotExist(err) {
+		m.error(err)
+	} else {
+		m.infof("Removed: %s", path)
+	}
+}
@@ -0,0 +1,132 @@
+package systemd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWithLogNamespace tests namespace validation and the generated journald configuration
+func TestWithLogNamespace(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "",
+		WithLogNamespace(JournalNamespace{
+			Name:         "payments",
+			Storage:      "persistent",
+			SystemMaxUse: "2G",
+			MaxFileSize:  "128M",
+			MaxRetention: 30 * 24 * time.Hour,
+		}))
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	if !slices.Contains(cfg.ServiceLines, "LogNamespace=payments") {
+		t.Errorf("Expected LogNamespace=payments in %v", cfg.ServiceLines)
+	}
+
+	expected := `# Journal namespace payments of bin-myapp.service
+[Journal]
+Storage=persistent
+SystemMaxUse=2G
+SystemMaxFileSize=128M
+MaxRetentionSec=2592000s
+`
+	if got := renderJournaldConf(&cfg); got != expected {
+		t.Errorf("Expected journald configuration:\n%s\ngot:\n%s", expected, got)
+	}
+	if got := journaldNamespacePath("payments"); got != "/etc/systemd/journald@payments.conf" {
+		t.Errorf("Unexpected namespace path %s", got)
+	}
+
+	invalid := []JournalNamespace{
+		{Name: ""},
+		{Name: "bad name"},
+		{Name: ".hidden"},
+		{Name: "ns", Storage: "disk"},
+		{Name: "ns", SystemMaxUse: "lots"},
+		{Name: "ns", MaxRetention: 1500 * time.Millisecond},
+	}
+	for _, ns := range invalid {
+		cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "", WithLogNamespace(ns))
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected validation error for %+v", ns)
+		}
+	}
+}
+
+// TestJournaldOptions tests the per-service journald directives
+func TestJournaldOptions(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "",
+		WithLogLevelMax("warning"),
+		WithLogRateLimit(30*time.Second, 1000),
+		WithLogExtraFields(map[string]string{"TEAM": "payments", "TIER": "gold plus"}),
+		WithSyslogIdentifier("payd"))
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+
+	expected := []string{
+		"LogLevelMax=warning",
+		"LogRateLimitIntervalSec=30000ms",
+		"LogRateLimitBurst=1000",
+		`LogExtraFields="TEAM=payments"`,
+		`LogExtraFields="TIER=gold plus"`,
+		"SyslogIdentifier=payd",
+	}
+	if !slices.Equal(cfg.ServiceLines, expected) {
+		t.Errorf("Expected %v, got %v", expected, cfg.ServiceLines)
+	}
+	if cfg.ProgramName != "payd" {
+		t.Errorf("Expected ProgramName payd, got %q", cfg.ProgramName)
+	}
+
+	invalid := []ServiceOpt{
+		WithLogLevelMax("verbose"),
+		WithLogRateLimit(-time.Second, 10),
+		WithLogRateLimit(time.Second, -1),
+		WithLogExtraFields(map[string]string{"team": "x"}),
+		WithLogExtraFields(map[string]string{"TEAM": "a\"b"}),
+		WithSyslogIdentifier("two words"),
+	}
+	for i, opt := range invalid {
+		cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "", opt)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected validation error for option %d", i)
+		}
+	}
+}
+
+// TestDirectiveInUse tests detection of other units using a journal namespace
+func TestDirectiveInUse(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.service", "[Service]\nLogNamespace=other\n")
+
+	inUse, err := directiveInUse(dir, "LogNamespace", "payments")
+	if err != nil || inUse {
+		t.Errorf("Expected namespace unused, got %v (%v)", inUse, err)
+	}
+
+	write("b.service", "[Service]\n  LogNamespace=payments\n")
+	if inUse, err = directiveInUse(dir, "LogNamespace", "payments"); err != nil || !inUse {
+		t.Errorf("Expected namespace in use, got %v (%v)", inUse, err)
+	}
+}
+
+// TestJournalctlArgsNamespace tests reading logs from the service's namespace
+func TestJournalctlArgsNamespace(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "", WithLogNamespace(JournalNamespace{Name: "payments"}))
+	args, err := journalctlArgs(&cfg, LogOptions{})
+	if err != nil {
+		t.Fatalf("journalctlArgs failed: %v", err)
+	}
+	if !strings.Contains(strings.Join(args, " "), "--namespace payments") {
+		t.Errorf("Expected --namespace payments in %v", args)
+	}
+}
@@ -0,0 +1,204 @@
+package systemd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultLogDirMode is the mode of LogDir unless configured otherwise.
+const defaultLogDirMode os.FileMode = 0o750
+
+// selinuxEnforcePath exists when SELinux is enabled on the host.
+const selinuxEnforcePath = "/sys/fs/selinux/enforce"
+
+// groupNameRe matches group names usable in ACL entries and tmpfiles.d lines.
+var groupNameRe = regexp.MustCompile(`^[a-z_][a-z0-9_-]*\$?$`)
+
+// LogDirConfig controls how Install provisions LogDir.
+type LogDirConfig struct {
+	Mode       os.FileMode // Directory mode (defaults to 0750)
+	Owner      string      // Owning user (defaults to the service user, root with DynamicUser)
+	Group      string      // Owning group (defaults to the service group, root with DynamicUser)
+	Tmpfiles   bool        // Declare the directory in tmpfiles.d instead of creating it directly
+	ReadGroups []string    // Groups granted read access to the directory and its logs through ACLs
+}
+
+// WithLogDirConfig sets the mode, ownership and ACLs Install applies to LogDir.
+func WithLogDirConfig(d LogDirConfig) ServiceOpt {
+	return func(c *ServiceConfig) {
+		if d.Mode&^os.ModePerm != 0 {
+			c.invalid("LogDir", fmt.Errorf("invalid mode %o", d.Mode))
+			return
+		}
+		for _, g := range d.ReadGroups {
+			if !groupNameRe.MatchString(g) {
+				c.invalid("LogDir", fmt.Errorf("invalid read group %q", g))
+				return
+			}
+		}
+		c.LogDirSetup = &d
+	}
+}
+
+// logDirConfig returns the LogDir settings with defaults applied.
+func logDirConfig(c *ServiceConfig) LogDirConfig {
+	var d LogDirConfig
+	if c.LogDirSetup != nil {
+		d = *c.LogDirSetup
+	}
+	owner, group := logOwner(c)
+	if d.Mode == 0 {
+		d.Mode = defaultLogDirMode
+	}
+	if d.Owner == "" {
+		d.Owner = owner
+	}
+	if d.Group == "" {
+		d.Group = group
+	}
+	return d
+}
+
+// logDirACL returns the access and default ACL entries granting the read groups
+// access to LogDir and to log files created in it.
+func logDirACL(d LogDirConfig) string {
+	entries := make([]string, 0, 2*len(d.ReadGroups))
+	for _, g := range d.ReadGroups {
+		entries = append(entries, "g:"+g+":rX", "d:g:"+g+":rX")
+	}
+	return strings.Join(entries, ",")
+}
+
+// renderLogDirTmpfiles returns the tmpfiles.d lines declaring LogDir.
+func renderLogDirTmpfiles(c *ServiceConfig) []string {
+	d := logDirConfig(c)
+	lines := []string{fmt.Sprintf("d %s %04o %s %s -", c.LogDir, d.Mode, d.Owner, d.Group)}
+	if len(d.ReadGroups) > 0 {
+		var acl []string
+		for _, g := range d.ReadGroups {
+			acl = append(acl, "d:group:"+g+":r-X", "group:"+g+":r-X")
+		}
+		lines = append(lines, fmt.Sprintf("a+ %s - - - - %s", c.LogDir, strings.Join(acl, ",")))
+	}
+	return lines
+}
+
+// provisionLogDir creates LogDir with the configured mode and ownership, or
+// declares it in tmpfiles.d, and applies read ACLs. An existing directory is
+// left as is, but unsafe ownership or permissions are reported on the error channel.
+func (m *Manager) provisionLogDir() error {
+	c := m.cfg
+	d := logDirConfig(c)
+
+	// Install applied the tmpfiles.d declaration together with the other entries
+	if d.Tmpfiles {
+		return nil
+	}
+
+	info, err := os.Stat(c.LogDir)
+	switch {
+	case os.IsNotExist(err):
+		if err := createLogDir(c.LogDir, d); err != nil {
+			return err
+		}
+		m.infof("Log directory created: %s", c.LogDir)
+	case err != nil:
+		return err
+	case !info.IsDir():
+		return fmt.Errorf("log directory %s is not a directory", c.LogDir)
+	default:
+		if err := checkLogDirOwnership(c.LogDir, info, d); err != nil {
+			m.error(err)
+		}
+	}
+
+	if len(d.ReadGroups) > 0 {
+		if err := c.execCommand("setfacl", "-R", "-m", logDirACL(d), c.LogDir); err != nil {
+			return fmt.Errorf("failed to grant read access to %s: %w", c.LogDir, err)
+		}
+		m.infof("Read access granted on %s to %s", c.LogDir, strings.Join(d.ReadGroups, ", "))
+	}
+
+	// Directories created outside the policy's expected path get the wrong label
+	if _, err := os.Stat(selinuxEnforcePath); err == nil {
+		if err := c.execCommand("restorecon", "-R", c.LogDir); err != nil {
+			m.error(fmt.Errorf("failed to restore SELinux context of %s: %w", c.LogDir, err))
+		}
+	}
+
+	return nil
+}
+
+// createLogDir creates dir and its missing parents, then applies the configured
+// mode and ownership to dir itself.
+func createLogDir(dir string, d LogDirConfig) error {
+	uid, gid, err := lookupOwner(d.Owner, d.Group)
+	if err != nil {
+		return fmt.Errorf("log directory %s: %w", dir, err)
+	}
+	if err := os.MkdirAll(dir, d.Mode); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := os.Chown(dir, uid, gid); err != nil {
+		return fmt.Errorf("failed to set owner of log directory: %w", err)
+	}
+	// Chmod since MkdirAll is subject to the umask
+	if err := os.Chmod(dir, d.Mode); err != nil {
+		return fmt.Errorf("failed to set mode of log directory: %w", err)
+	}
+	return nil
+}
+
+// checkLogDirOwnership reports an existing log directory writable by others than
+// root and the configured owner and group.
+func checkLogDirOwnership(dir string, info os.FileInfo, d LogDirConfig) error {
+	fileUID, fileGID, ok := fileOwner(info)
+	if !ok {
+		return nil
+	}
+	uid, gid, err := lookupOwner(d.Owner, d.Group)
+	if err != nil {
+		return fmt.Errorf("log directory %s: %w", dir, err)
+	}
+
+	var problems []string
+	if fileUID != 0 && fileUID != uid {
+		problems = append(problems, fmt.Sprintf("owned by UID %d instead of %s", fileUID, d.Owner))
+	}
+	mode := info.Mode()
+	if mode&0o020 != 0 && fileGID != 0 && fileGID != gid {
+		problems = append(problems, fmt.Sprintf("writable by GID %d instead of %s", fileGID, d.Group))
+	}
+	if mode&0o002 != 0 && mode&os.ModeSticky == 0 {
+		problems = append(problems, "world-writable")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("log directory %s has unsafe ownership: %s", dir, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// lookupOwner resolves a user and group name to numeric IDs.
+func lookupOwner(owner, group string) (int, int, error) {
+	u, err := user.Lookup(owner)
+	if err != nil {
+		return 0, 0, err
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, 0, err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid UID %q for user %s: %w", u.Uid, owner, err)
+	}
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid GID %q for group %s: %w", g.Gid, group, err)
+	}
+	return uid, gid, nil
+}
@@ -0,0 +1,133 @@
+package systemd
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// currentOwner returns the names of the current user and its primary group.
+func currentOwner(t *testing.T) (string, string) {
+	t.Helper()
+	u, err := user.Current()
+	if err != nil {
+		t.Skipf("Cannot look up current user: %v", err)
+	}
+	g, err := user.LookupGroupId(u.Gid)
+	if err != nil {
+		t.Skipf("Cannot look up current group: %v", err)
+	}
+	return u.Username, g.Name
+}
+
+// TestLogDirConfigDefaults tests the LogDir settings applied without options
+func TestLogDirConfigDefaults(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svcgrp", "/usr/bin/myapp", "/var/log/myapp")
+	d := logDirConfig(&cfg)
+	if d.Mode != 0o750 || d.Owner != "svc" || d.Group != "svcgrp" {
+		t.Errorf("Expected 0750 svc:svcgrp, got %04o %s:%s", d.Mode, d.Owner, d.Group)
+	}
+
+	cfg = NewServiceConfig("svc", "svcgrp", "/usr/bin/myapp", "/var/log/myapp", WithDynamicUser(),
+		WithLogDirConfig(LogDirConfig{Mode: 0o755}))
+	d = logDirConfig(&cfg)
+	if d.Mode != 0o755 || d.Owner != "root" || d.Group != "root" {
+		t.Errorf("Expected 0755 root:root, got %04o %s:%s", d.Mode, d.Owner, d.Group)
+	}
+
+	for _, bad := range []LogDirConfig{{Mode: os.ModeDir | 0o755}, {ReadGroups: []string{"bad group"}}} {
+		cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "/var/log/myapp", WithLogDirConfig(bad))
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected validation error for %+v", bad)
+		}
+	}
+}
+
+// TestRenderLogDirTmpfiles tests the tmpfiles.d declaration of LogDir
+func TestRenderLogDirTmpfiles(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svcgrp", "/usr/bin/myapp", "/var/log/myapp",
+		WithLogDirConfig(LogDirConfig{Tmpfiles: true, ReadGroups: []string{"monitoring"}}))
+
+	expected := `# Paths of bin-myapp.service
+d /var/log/myapp 0750 svc svcgrp -
+a+ /var/log/myapp - - - - d:group:monitoring:r-X,group:monitoring:r-X
+`
+	if got := renderTmpfilesConf(&cfg); got != expected {
+		t.Errorf("Expected tmpfiles configuration:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+// TestProvisionLogDir tests creation of a missing log directory with ACLs
+func TestProvisionLogDir(t *testing.T) {
+	owner, group := currentOwner(t)
+	dir := fakeCommands(t, map[string]string{"setfacl": "exit 0"})
+	logDir := filepath.Join(t.TempDir(), "log", "myapp")
+
+	cfg := NewServiceConfig(owner, group, "/usr/bin/myapp", logDir,
+		WithLogDirConfig(LogDirConfig{Mode: 0o2750, ReadGroups: []string{"monitoring"}}))
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected setgid mode to be rejected")
+	}
+
+	cfg = NewServiceConfig(owner, group, "/usr/bin/myapp", logDir,
+		WithLogDirConfig(LogDirConfig{Mode: 0o710, ReadGroups: []string{"monitoring"}}))
+	m := NewManager(&cfg)
+	if err := m.provisionLogDir(); err != nil {
+		t.Fatalf("provisionLogDir failed: %v", err)
+	}
+
+	info, err := os.Stat(logDir)
+	if err != nil {
+		t.Fatalf("Log directory not created: %v", err)
+	}
+	if info.Mode().Perm() != 0o710 {
+		t.Errorf("Expected mode 0710, got %04o", info.Mode().Perm())
+	}
+	expected := "setfacl -R -m g:monitoring:rX,d:g:monitoring:rX " + logDir
+	if calls := fakeCalls(t, dir); !strings.Contains(calls, expected) {
+		t.Errorf("Expected %q, got %q", expected, calls)
+	}
+}
+
+// TestCheckLogDirOwnership tests reporting of unsafe existing log directories
+func TestCheckLogDirOwnership(t *testing.T) {
+	owner, group := currentOwner(t)
+	logDir := t.TempDir()
+	d := LogDirConfig{Owner: owner, Group: group}
+
+	if err := os.Chmod(logDir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(logDir)
+	if err := checkLogDirOwnership(logDir, info, d); err != nil {
+		t.Errorf("Expected safe directory, got %v", err)
+	}
+
+	if err := os.Chmod(logDir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	info, _ = os.Stat(logDir)
+	if err := checkLogDirOwnership(logDir, info, d); err == nil || !strings.Contains(err.Error(), "world-writable") {
+		t.Errorf("Expected world-writable error, got %v", err)
+	}
+
+	// An existing directory is reported but left untouched
+	errChan := make(chan error, 1)
+	cfg := NewServiceConfig(owner, group, "/usr/bin/myapp", logDir)
+	if err := NewManager(&cfg, WithErrorChan(errChan)).provisionLogDir(); err != nil {
+		t.Fatalf("provisionLogDir failed: %v", err)
+	}
+	select {
+	case err := <-errChan:
+		if !strings.Contains(err.Error(), "unsafe ownership") {
+			t.Errorf("Unexpected error %v", err)
+		}
+	default:
+		t.Error("Expected unsafe ownership to be reported")
+	}
+	if info, _ = os.Stat(logDir); info.Mode().Perm() != 0o777 {
+		t.Errorf("Expected existing directory mode to be kept, got %04o", info.Mode().Perm())
+	}
+}
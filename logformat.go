@@ -0,0 +1,158 @@
+package systemd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LogFormat selects how rsyslog writes the messages of a stream to its file.
+type LogFormat int
+
+const (
+	// FormatRaw writes the message only, one per line.
+	FormatRaw LogFormat = iota
+	// FormatRFC5424 writes RFC 5424 syslog lines (RSYSLOG_SyslogProtocol23Format).
+	FormatRFC5424
+	// FormatRFC3164 writes traditional BSD syslog lines (RSYSLOG_TraditionalFileFormat).
+	FormatRFC3164
+	// FormatJSON writes one JSON object per line with the selected fields.
+	FormatJSON
+)
+
+// LogField names a message property included in JSON lines.
+type LogField string
+
+// Properties available in JSON lines. The message is always included as "message".
+const (
+	FieldTimestamp      LogField = "timestamp"       // Reported time in RFC 3339 format
+	FieldHostname       LogField = "hostname"        // Originating host
+	FieldSyslogTag      LogField = "syslogtag"       // Syslog tag, e.g. "myapp[1234]:"
+	FieldProgramName    LogField = "programname"     // Program name part of the tag
+	FieldPID            LogField = "pid"             // Process ID (procid)
+	FieldSeverity       LogField = "severity"        // Severity name, e.g. "info"
+	FieldFacility       LogField = "facility"        // Facility name, e.g. "daemon"
+	FieldStructuredData LogField = "structured_data" // RFC 5424 structured data
+)
+
+// DefaultJSONFields are the JSON properties used when a StreamFormat lists none.
+var DefaultJSONFields = []LogField{FieldTimestamp, FieldHostname, FieldSyslogTag, FieldPID, FieldSeverity}
+
+// logFieldProperties maps JSON fields to rsyslog property replacer expressions.
+var logFieldProperties = map[LogField]string{
+	FieldTimestamp:      "%timereported:::date-rfc3339%",
+	FieldHostname:       "%hostname:::json%",
+	FieldSyslogTag:      "%syslogtag:::json%",
+	FieldProgramName:    "%programname:::json%",
+	FieldPID:            "%procid:::json%",
+	FieldSeverity:       "%syslogseverity-text%",
+	FieldFacility:       "%syslogfacility-text%",
+	FieldStructuredData: "%structured-data:::json%",
+}
+
+// StreamFormat describes the output format of a log stream.
+type StreamFormat struct {
+	Format LogFormat  // Line format
+	Fields []LogField // JSON properties (FormatJSON only; defaults to DefaultJSONFields)
+}
+
+// WithLogFormat sets the default output format of all log streams.
+func WithLogFormat(f StreamFormat) ServiceOpt {
+	return func(c *ServiceConfig) {
+		c.LogFormat = f
+	}
+}
+
+// WithStreamFormat sets the output format of a single log stream,
+// overriding the default set by WithLogFormat.
+func WithStreamFormat(name string, f StreamFormat) ServiceOpt {
+	return func(c *ServiceConfig) {
+		if c.StreamFormats == nil {
+			c.StreamFormats = make(map[string]StreamFormat)
+		}
+		c.StreamFormats[name] = f
+	}
+}
+
+// WithFormattedStream adds a named log stream written in the given format.
+// This is equivalent to WithStream followed by WithStreamFormat.
+func WithFormattedStream(name, file string, f StreamFormat) ServiceOpt {
+	return func(c *ServiceConfig) {
+		WithStream(name, file)(c)
+		WithStreamFormat(name, f)(c)
+	}
+}
+
+// streamFormat returns the effective format of a stream.
+func streamFormat(c *ServiceConfig, stream string) StreamFormat {
+	if f, ok := c.StreamFormats[stream]; ok {
+		return f
+	}
+	return c.LogFormat
+}
+
+// validateFormats checks the configured stream formats.
+func validateFormats(c *ServiceConfig) []error {
+	var errs []error
+	check := func(what string, f StreamFormat) {
+		if f.Format < FormatRaw || f.Format > FormatJSON {
+			errs = append(errs, fmt.Errorf("%s: unknown log format %d", what, f.Format))
+		}
+		if f.Format != FormatJSON && len(f.Fields) > 0 {
+			errs = append(errs, fmt.Errorf("%s: fields are only supported for JSON format", what))
+		}
+		for _, field := range f.Fields {
+			if _, ok := logFieldProperties[field]; !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown JSON field %q", what, field))
+			}
+		}
+	}
+
+	check("log format", c.LogFormat)
+	for _, name := range sortedStreams(c.Streams) {
+		if f, ok := c.StreamFormats[name]; ok {
+			check(fmt.Sprintf("stream %q", name), f)
+		}
+	}
+	for name := range c.StreamFormats {
+		if _, ok := c.Streams[name]; !ok {
+			errs = append(errs, fmt.Errorf("format for unknown stream %q", name))
+		}
+	}
+	return errs
+}
+
+// streamTemplate returns the name of the rsyslog template used by a stream and,
+// for templates generated by this package, its definition.
+func streamTemplate(c *ServiceConfig, stream string) (name, definition string) {
+	f := streamFormat(c, stream)
+	switch f.Format {
+	case FormatRFC5424:
+		return "RSYSLOG_SyslogProtocol23Format", ""
+	case FormatRFC3164:
+		return "RSYSLOG_TraditionalFileFormat", ""
+	case FormatJSON:
+		return jsonTemplate(c.UniqueName, f.Fields)
+	default:
+		return c.UniqueName, fmt.Sprintf(`template(name="%s" type="string" string="%%msg%%\n")`, c.UniqueName)
+	}
+}
+
+// jsonTemplate renders a JSON lines template for the given fields. Templates are
+// named after their fields so streams sharing a field set share the template.
+func jsonTemplate(uniqueName string, fields []LogField) (name, definition string) {
+	if len(fields) == 0 {
+		fields = DefaultJSONFields
+	}
+
+	nameParts := []string{uniqueName, "json"}
+	var props []string
+	for _, field := range fields {
+		nameParts = append(nameParts, string(field))
+		props = append(props, fmt.Sprintf(`\"%s\":\"%s\"`, field, logFieldProperties[field]))
+	}
+	props = append(props, `\"message\":\"%msg:::json%\"`)
+
+	name = strings.Join(nameParts, "-")
+	definition = fmt.Sprintf(`template(name="%s" type="string" string="{%s}\n")`, name, strings.Join(props, ","))
+	return name, definition
+}
@@ -0,0 +1,96 @@
+package systemd
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestStreamTemplate tests template selection and generation per format
+func TestStreamTemplate(t *testing.T) {
+	cfg := ServiceConfig{UniqueName: "svc"}
+
+	tests := []struct {
+		name       string
+		format     StreamFormat
+		template   string
+		definition string
+	}{
+		{"raw", StreamFormat{}, "svc", `template(name="svc" type="string" string="%msg%\n")`},
+		{"rfc5424", StreamFormat{Format: FormatRFC5424}, "RSYSLOG_SyslogProtocol23Format", ""},
+		{"rfc3164", StreamFormat{Format: FormatRFC3164}, "RSYSLOG_TraditionalFileFormat", ""},
+		{
+			"json", StreamFormat{Format: FormatJSON, Fields: []LogField{FieldTimestamp, FieldSeverity}},
+			"svc-json-timestamp-severity",
+			`template(name="svc-json-timestamp-severity" type="string" string="{` +
+				`\"timestamp\":\"%timereported:::date-rfc3339%\",` +
+				`\"severity\":\"%syslogseverity-text%\",` +
+				`\"message\":\"%msg:::json%\"}\n")`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.LogFormat = tt.format
+			name, definition := streamTemplate(&cfg, "app")
+			if name != tt.template {
+				t.Errorf("Expected template %q, got %q", tt.template, name)
+			}
+			if definition != tt.definition {
+				t.Errorf("Definition mismatch.\nExpected:\n%s\nGot:\n%s", tt.definition, definition)
+			}
+		})
+	}
+}
+
+// TestRenderRsyslogConfFormats tests per-stream formats in the rendered configuration
+func TestRenderRsyslogConfFormats(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/app", "/var/log/app",
+		WithStream("CORE", "core.log"),
+		WithFormattedStream("ACCESS", "access.json", StreamFormat{Format: FormatJSON}),
+		WithFormattedStream("AUDIT", "audit.json", StreamFormat{Format: FormatJSON}),
+		WithFormattedStream("SYS", "sys.log", StreamFormat{Format: FormatRFC5424}),
+	)
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+
+	conf := renderRsyslogConf(&cfg)
+
+	jsonName := "bin-app-json-timestamp-hostname-syslogtag-pid-severity"
+	if n := strings.Count(conf, `template(name="`+jsonName+`"`); n != 1 {
+		t.Errorf("Expected shared JSON template to be defined once, found %d times:\n%s", n, conf)
+	}
+	if n := strings.Count(conf, `template="`+jsonName+`"`); n != 2 {
+		t.Errorf("Expected two streams to use the JSON template, found %d:\n%s", n, conf)
+	}
+	if !strings.Contains(conf, `template(name="bin-app" type="string" string="%msg%\n")`) {
+		t.Errorf("Expected raw template for CORE stream:\n%s", conf)
+	}
+	if !strings.Contains(conf, `file="/var/log/app/sys.log" template="RSYSLOG_SyslogProtocol23Format"`) {
+		t.Errorf("Expected SYS stream to use the RFC 5424 template:\n%s", conf)
+	}
+}
+
+// TestValidateFormats tests rejection of invalid stream formats
+func TestValidateFormats(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []ServiceOpt
+	}{
+		{"unknown field", []ServiceOpt{WithFormattedStream("a", "a.log",
+			StreamFormat{Format: FormatJSON, Fields: []LogField{"bogus"}})}},
+		{"fields without json", []ServiceOpt{WithFormattedStream("a", "a.log",
+			StreamFormat{Format: FormatRaw, Fields: []LogField{FieldPID}})}},
+		{"unknown stream", []ServiceOpt{WithStreamFormat("missing", StreamFormat{Format: FormatJSON})}},
+		{"unknown format", []ServiceOpt{WithLogFormat(StreamFormat{Format: LogFormat(42)})}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewServiceConfig("svc", "svc", "/usr/bin/app", "/var/log/app", tt.opts...)
+			if err := cfg.Validate(); err == nil {
+				t.Error("Expected validation error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,432 @@
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// rsyslogReopenCommand makes rsyslog reopen its output files after rotation.
+const rsyslogReopenCommand = "systemctl kill -s HUP rsyslog.service"
+
+// dateFormatRe matches the dateformat values accepted by logrotate.
+var dateFormatRe = regexp.MustCompile(`^[-_.A-Za-z0-9]*(%[YmdHMSVs][-_.A-Za-z0-9]*)+$`)
+
+// RotateFrequency is the time interval at which logrotate rotates a log file.
+type RotateFrequency string
+
+const (
+	RotateHourly  RotateFrequency = "hourly"
+	RotateDaily   RotateFrequency = "daily"
+	RotateWeekly  RotateFrequency = "weekly"
+	RotateMonthly RotateFrequency = "monthly"
+	RotateYearly  RotateFrequency = "yearly"
+)
+
+// CompressMethod selects how logrotate compresses rotated log files.
+type CompressMethod int
+
+const (
+	CompressNone CompressMethod = iota
+	CompressGzip
+	CompressBzip2
+	CompressXz
+	CompressZstd
+)
+
+// compressCommands holds the compress command, uncompress command and file
+// extension of the methods logrotate does not handle natively.
+var compressCommands = map[CompressMethod][3]string{
+	CompressBzip2: {"/usr/bin/bzip2", "/usr/bin/bunzip2", ".bz2"},
+	CompressXz:    {"/usr/bin/xz", "/usr/bin/unxz", ".xz"},
+	CompressZstd:  {"/usr/bin/zstd", "/usr/bin/unzstd", ".zst"},
+}
+
+// RotatePolicy describes how logrotate rotates a stream's log file.
+//
+// Size rotates on size alone and cannot be combined with Frequency, MaxSize or
+// MinSize. Rotated files are reopened by signalling rsyslog unless CopyTruncate
+// is set; PostRotate commands run after that signal.
+type RotatePolicy struct {
+	Frequency     RotateFrequency // Time-based rotation interval (empty for logrotate's default)
+	Count         int             // Number of rotated files kept (0 keeps DefaultRotatePolicy's 8)
+	Size          string          // Rotate only when larger than this size, e.g. "100M"
+	MaxSize       string          // Also rotate before the interval when larger than this size
+	MinSize       string          // Skip interval rotations while smaller than this size
+	MaxAge        int             // Remove rotated files older than this many days (0 keeps them)
+	DateExt       bool            // Suffix rotated files with a date instead of a number
+	DateFormat    string          // strftime-like date suffix, e.g. "-%Y%m%d" (requires DateExt)
+	Compress      CompressMethod  // Compression of rotated files
+	DelayCompress bool            // Compress on the next rotation instead of immediately
+	CopyTruncate  bool            // Copy and truncate the file instead of recreating it
+	SuUser        string          // User logrotate switches to for rotation
+	SuGroup       string          // Group logrotate switches to for rotation (required with SuUser)
+	PreRotate     []string        // Commands run before rotation
+	PostRotate    []string        // Commands run after rotation
+}
+
+// DefaultRotatePolicy returns the policy used when none is configured: rotation
+// at 100M keeping 8 gzip-compressed files, the latest one left uncompressed.
+func DefaultRotatePolicy() RotatePolicy {
+	return RotatePolicy{
+		Count:         8,
+		Size:          "100M",
+		Compress:      CompressGzip,
+		DelayCompress: true,
+	}
+}
+
+// WithRotatePolicy sets the rotation policy of all streams and enables logrotate.
+func WithRotatePolicy(p RotatePolicy) ServiceOpt {
+	return func(c *ServiceConfig) {
+		c.MakeLogrotate = true
+		c.RotatePolicy = &p
+	}
+}
+
+// WithStreamRotatePolicy sets the rotation policy of a single stream, overriding
+// the service policy, and enables logrotate.
+func WithStreamRotatePolicy(name string, p RotatePolicy) ServiceOpt {
+	return func(c *ServiceConfig) {
+		c.MakeLogrotate = true
+		if c.StreamRotatePolicies == nil {
+			c.StreamRotatePolicies = make(map[string]RotatePolicy)
+		}
+		c.StreamRotatePolicies[name] = p
+	}
+}
+
+// validate checks the policy for invalid values and conflicting settings.
+func (p *RotatePolicy) validate() error {
+	var errs []error
+	switch p.Frequency {
+	case "", RotateHourly, RotateDaily, RotateWeekly, RotateMonthly, RotateYearly:
+	default:
+		errs = append(errs, fmt.Errorf("unknown frequency %q", p.Frequency))
+	}
+	if p.Count < 0 {
+		errs = append(errs, fmt.Errorf("invalid count %d", p.Count))
+	}
+	if p.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("invalid maxage %d", p.MaxAge))
+	}
+	for key, size := range map[string]string{"size": p.Size, "maxsize": p.MaxSize, "minsize": p.MinSize} {
+		if size == "" {
+			continue
+		}
+		if _, err := ParseSize(size, false); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	if p.Size != "" && (p.Frequency != "" || p.MaxSize != "" || p.MinSize != "") {
+		errs = append(errs, errors.New("size cannot be combined with frequency, maxsize or minsize"))
+	}
+	if p.DateFormat != "" {
+		if !p.DateExt {
+			errs = append(errs, errors.New("dateformat requires dateext"))
+		}
+		if !dateFormatRe.MatchString(p.DateFormat) {
+			errs = append(errs, fmt.Errorf("invalid dateformat %q", p.DateFormat))
+		}
+	}
+	if p.Compress < CompressNone || p.Compress > CompressZstd {
+		errs = append(errs, fmt.Errorf("unknown compress method %d", p.Compress))
+	}
+	if p.DelayCompress && p.Compress == CompressNone {
+		errs = append(errs, errors.New("delaycompress requires compression"))
+	}
+	if (p.SuUser == "") != (p.SuGroup == "") {
+		errs = append(errs, errors.New("su requires both user and group"))
+	}
+	for _, cmd := range append(append([]string(nil), p.PreRotate...), p.PostRotate...) {
+		if cmd == "" || strings.Contains(cmd, "\n") || strings.TrimSpace(cmd) == "endscript" {
+			errs = append(errs, fmt.Errorf("invalid rotate script command %q", cmd))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateRotatePolicies checks the service and per-stream rotation policies.
+func validateRotatePolicies(c *ServiceConfig) []error {
+	var errs []error
+	if c.RotatePolicy != nil {
+		if err := c.RotatePolicy.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("rotate policy: %w", err))
+		}
+	}
+	for _, name := range sortedStreams(c.Streams) {
+		if p, ok := c.StreamRotatePolicies[name]; ok {
+			if err := p.validate(); err != nil {
+				errs = append(errs, fmt.Errorf("stream %q rotate policy: %w", name, err))
+			}
+		}
+	}
+	for name := range c.StreamRotatePolicies {
+		if _, ok := c.Streams[name]; !ok {
+			errs = append(errs, fmt.Errorf("rotate policy for unknown stream %q", name))
+		}
+	}
+	return errs
+}
+
+// streamRotatePolicy returns the rotation policy applying to a stream.
+func streamRotatePolicy(c *ServiceConfig, stream string) RotatePolicy {
+	if p, ok := c.StreamRotatePolicies[stream]; ok {
+		return p
+	}
+	if c.RotatePolicy != nil {
+		return *c.RotatePolicy
+	}
+	return DefaultRotatePolicy()
+}
+
+// WithSingleLogrotateFile renders the rotation of all streams into a single
+// /etc/logrotate.d/<UniqueName> file instead of one file per stream. Streams
+// sharing a policy are grouped in one block so rsyslog is signalled once per
+// rotation.
+func WithSingleLogrotateFile() ServiceOpt {
+	return func(c *ServiceConfig) {
+		c.MakeLogrotate = true
+		c.SingleLogrotateFile = true
+	}
+}
+
+// renderLogrotateConf generates the logrotate configuration of a stream.
+func renderLogrotateConf(c *ServiceConfig, stream string) string {
+	return renderLogrotateBlock(c, []string{stream}, streamRotatePolicy(c, stream))
+}
+
+// renderLogrotateFile generates the single logrotate file of the service, with
+// one block per distinct rotation policy.
+func renderLogrotateFile(c *ServiceConfig) string {
+	var keys []string
+	groups := make(map[string][]string)
+	policies := make(map[string]RotatePolicy)
+	for _, stream := range sortedStreams(c.Streams) {
+		p := streamRotatePolicy(c, stream)
+		key := renderLogrotateBlock(c, nil, p)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+			policies[key] = p
+		}
+		groups[key] = append(groups[key], stream)
+	}
+
+	blocks := make([]string, 0, len(keys))
+	for _, key := range keys {
+		blocks = append(blocks, renderLogrotateBlock(c, groups[key], policies[key]))
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+// renderLogrotateBlock generates a logrotate block rotating the files of the
+// given streams with policy p.
+func renderLogrotateBlock(c *ServiceConfig, streams []string, p RotatePolicy) string {
+	owner, group := logOwner(c)
+
+	files := make([]string, 0, len(streams))
+	for _, stream := range streams {
+		files = append(files, fmt.Sprintf("%s/%s", c.LogDir, c.Streams[stream]))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s {\n", strings.Join(files, " "))
+	line := func(format string, a ...interface{}) {
+		fmt.Fprintf(&b, "\t"+format+"\n", a...)
+	}
+
+	if p.Frequency != "" {
+		line("%s", p.Frequency)
+	}
+	count := p.Count
+	if count == 0 {
+		count = DefaultRotatePolicy().Count
+	}
+	line("rotate %d", count)
+	if p.Size != "" {
+		line("size %s", p.Size)
+	}
+	if p.MaxSize != "" {
+		line("maxsize %s", p.MaxSize)
+	}
+	if p.MinSize != "" {
+		line("minsize %s", p.MinSize)
+	}
+	if p.MaxAge > 0 {
+		line("maxage %d", p.MaxAge)
+	}
+	if p.DateExt {
+		line("dateext")
+	}
+	if p.DateFormat != "" {
+		line("dateformat %s", p.DateFormat)
+	}
+	if p.Compress != CompressNone {
+		line("compress")
+		if cmds, ok := compressCommands[p.Compress]; ok {
+			line("compresscmd %s", cmds[0])
+			line("uncompresscmd %s", cmds[1])
+			line("compressext %s", cmds[2])
+		}
+	}
+	if p.DelayCompress {
+		line("delaycompress")
+	}
+	line("missingok")
+	line("notifempty")
+	if p.CopyTruncate {
+		line("copytruncate")
+	} else {
+		line("create 0640 %s %s", owner, group)
+	}
+	if p.SuUser != "" {
+		line("su %s %s", p.SuUser, p.SuGroup)
+	}
+
+	postRotate := p.PostRotate
+	if !p.CopyTruncate {
+		postRotate = append([]string{rsyslogReopenCommand}, postRotate...)
+	}
+	if len(p.PreRotate) > 0 || len(postRotate) > 0 {
+		line("sharedscripts")
+	}
+	for _, script := range []struct {
+		name string
+		cmds []string
+	}{{"prerotate", p.PreRotate}, {"postrotate", postRotate}} {
+		if len(script.cmds) == 0 {
+			continue
+		}
+		line("%s", script.name)
+		for _, cmd := range script.cmds {
+			line("\t%s", cmd)
+		}
+		line("endscript")
+	}
+
+	b.WriteString("}")
+	return b.String()
+}
+
+// renderLogrotateConfs generates the logrotate files of the service keyed by path:
+// a single file with WithSingleLogrotateFile, otherwise one file per stream.
+func renderLogrotateConfs(c *ServiceConfig) map[string]string {
+	if c.SingleLogrotateFile {
+		return map[string]string{logrotateCorePath(c): renderLogrotateFile(c)}
+	}
+	confs := make(map[string]string, len(c.Streams))
+	for streamName := range c.Streams {
+		confs[logrotateCorePath(c)+"-"+streamName] = renderLogrotateConf(c, streamName)
+	}
+	return confs
+}
+
+// writeLogrotateConfs validates and writes the logrotate configuration of the
+// service, then removes files left over from the other layout.
+func writeLogrotateConfs(c *ServiceConfig) error {
+	if !c.MakeLogrotate || c.Streams == nil {
+		return nil
+	}
+
+	confs := renderLogrotateConfs(c)
+	paths := make([]string, 0, len(confs))
+	for path := range confs {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	// Validate everything before touching the installed configuration
+	for _, path := range paths {
+		if err := validateLogrotateConf(c, confs[path]); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	for _, path := range paths {
+		if err := c.writeFile(path, []byte(confs[path])); err != nil {
+			return fmt.Errorf("failed to write logrotate config %s: %w", path, err)
+		}
+	}
+
+	installed, err := logrotatePaths(c, logrotateDir)
+	if err != nil {
+		return fmt.Errorf("failed to find stale logrotate configs: %w", err)
+	}
+	for _, path := range installed {
+		if _, ok := confs[path]; ok {
+			continue
+		}
+		if err := c.removeFile(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale logrotate config: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateLogrotateConf checks a logrotate configuration with a dry run against
+// a temporary state file, so the host's rotation state is left untouched.
+func validateLogrotateConf(c *ServiceConfig, content string) error {
+	dir, err := os.MkdirTemp("", "logrotate-check-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	conf := filepath.Join(dir, "logrotate.conf")
+	if err := os.WriteFile(conf, []byte(content), 0o600); err != nil {
+		return err
+	}
+
+	stdout, stderr, err := c.runCommand("logrotate", "--debug", "--state", filepath.Join(dir, "state"), conf)
+	if err != nil {
+		return fmt.Errorf("invalid logrotate configuration: %w", err)
+	}
+	// logrotate reports some syntax errors without failing
+	for _, line := range strings.Split(string(stdout)+string(stderr), "\n") {
+		if strings.HasPrefix(line, "error:") {
+			return fmt.Errorf("invalid logrotate configuration: %s", line)
+		}
+	}
+	return nil
+}
+
+// logrotateDir is the directory logrotate includes configurations from.
+const logrotateDir = "/etc/logrotate.d"
+
+// logrotateCorePath returns the base file path for logrotate configurations.
+// Individual stream configurations append "-{streamname}" to this path.
+func logrotateCorePath(c *ServiceConfig) string {
+	return filepath.Join(logrotateDir, c.UniqueName)
+}
+
+// logrotatePaths returns every logrotate file in dir the service may own, in
+// both the single-file and per-stream layouts. Per-stream files are found by
+// name, so files of streams since removed from the configuration are included;
+// files of other services sharing the name prefix are told apart by LogDir.
+func logrotatePaths(c *ServiceConfig, dir string) ([]string, error) {
+	core := filepath.Join(dir, c.UniqueName)
+	matches, err := filepath.Glob(core + "-*")
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{core}
+	for _, path := range matches {
+		data, err := os.ReadFile(path) // #nosec G304
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(string(data), c.LogDir+"/") {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,230 @@
+package systemd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRenderLogrotateConfDefault tests the configuration rendered without a policy
+func TestRenderLogrotateConfDefault(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svcgrp", "/usr/bin/myapp", "/var/log/myapp",
+		WithLogrotate(), WithStream("app", "app.log"))
+
+	expected := `/var/log/myapp/app.log {
+	rotate 8
+	size 100M
+	compress
+	delaycompress
+	missingok
+	notifempty
+	create 0640 svc svcgrp
+	sharedscripts
+	postrotate
+		systemctl kill -s HUP rsyslog.service
+	endscript
+}`
+	if got := renderLogrotateConf(&cfg, "app"); got != expected {
+		t.Errorf("Expected logrotate configuration:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+// TestRenderLogrotateConfDefaultCount tests that a policy without Count keeps the default number of files
+func TestRenderLogrotateConfDefaultCount(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svcgrp", "/usr/bin/myapp", "/var/log/myapp",
+		WithStream("app", "app.log"), WithRotatePolicy(RotatePolicy{Frequency: RotateDaily}))
+
+	if got := renderLogrotateConf(&cfg, "app"); !strings.Contains(got, "\trotate 8\n") {
+		t.Errorf("Expected rotate 8 in logrotate configuration:\n%s", got)
+	}
+}
+
+// TestRenderLogrotateConfPolicy tests service and per-stream rotation policies
+func TestRenderLogrotateConfPolicy(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svcgrp", "/usr/bin/myapp", "/var/log/myapp",
+		WithStreams(map[string]string{"app": "app.log", "audit": "audit.log"}),
+		WithRotatePolicy(RotatePolicy{
+			Frequency:  RotateDaily,
+			Count:      14,
+			MaxSize:    "500M",
+			DateExt:    true,
+			DateFormat: "-%Y%m%d",
+			Compress:   CompressZstd,
+			SuUser:     "svc",
+			SuGroup:    "svcgrp",
+			PostRotate: []string{"/usr/bin/myapp-notify rotated"},
+		}),
+		WithStreamRotatePolicy("audit", RotatePolicy{
+			Frequency:    RotateMonthly,
+			Count:        12,
+			MaxAge:       400,
+			CopyTruncate: true,
+			PreRotate:    []string{"/usr/bin/audit-flush"},
+		}))
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	if !cfg.MakeLogrotate {
+		t.Error("Expected rotate policy to enable logrotate")
+	}
+
+	expected := `/var/log/myapp/app.log {
+	daily
+	rotate 14
+	maxsize 500M
+	dateext
+	dateformat -%Y%m%d
+	compress
+	compresscmd /usr/bin/zstd
+	uncompresscmd /usr/bin/unzstd
+	compressext .zst
+	missingok
+	notifempty
+	create 0640 svc svcgrp
+	su svc svcgrp
+	sharedscripts
+	postrotate
+		systemctl kill -s HUP rsyslog.service
+		/usr/bin/myapp-notify rotated
+	endscript
+}`
+	if got := renderLogrotateConf(&cfg, "app"); got != expected {
+		t.Errorf("Expected logrotate configuration:\n%s\ngot:\n%s", expected, got)
+	}
+
+	audit := renderLogrotateConf(&cfg, "audit")
+	for _, want := range []string{"\tmonthly\n", "\tmaxage 400\n", "\tcopytruncate\n", "\tprerotate\n\t\t/usr/bin/audit-flush\n"} {
+		if !strings.Contains(audit, want) {
+			t.Errorf("Expected %q in audit configuration:\n%s", want, audit)
+		}
+	}
+	for _, unwanted := range []string{"create ", "HUP", "compress"} {
+		if strings.Contains(audit, unwanted) {
+			t.Errorf("Unexpected %q in audit configuration:\n%s", unwanted, audit)
+		}
+	}
+}
+
+// TestRotatePolicyValidation tests rejected and conflicting rotation settings
+func TestRotatePolicyValidation(t *testing.T) {
+	invalid := map[string]RotatePolicy{
+		"frequency":      {Frequency: "fortnightly"},
+		"count":          {Count: -1},
+		"maxage":         {MaxAge: -1},
+		"size":           {Size: "big"},
+		"size+frequency": {Size: "10M", Frequency: RotateDaily},
+		"size+maxsize":   {Size: "10M", MaxSize: "20M"},
+		"dateformat":     {DateFormat: "-%Y"},
+		"bad dateformat": {DateExt: true, DateFormat: "/%Y"},
+		"delaycompress":  {DelayCompress: true},
+		"compress":       {Compress: CompressZstd + 1},
+		"su":             {SuUser: "svc"},
+		"script":         {PostRotate: []string{"endscript"}},
+	}
+	for name, p := range invalid {
+		cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "/var/log/myapp",
+			WithStream("app", "app.log"), WithRotatePolicy(p))
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected validation error for %s", name)
+		}
+	}
+
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "/var/log/myapp",
+		WithStream("app", "app.log"), WithStreamRotatePolicy("other", DefaultRotatePolicy()))
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "unknown stream") {
+		t.Errorf("Expected unknown stream error, got %v", err)
+	}
+
+	p := DefaultRotatePolicy()
+	if err := p.validate(); err != nil {
+		t.Errorf("Expected default policy to be valid, got %v", err)
+	}
+}
+
+// TestRenderLogrotateFile tests grouping of streams in a single logrotate file
+func TestRenderLogrotateFile(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "/var/log/myapp",
+		WithStreams(map[string]string{"app": "app.log", "http": "http.log", "audit": "audit.log"}),
+		WithSingleLogrotateFile(),
+		WithStreamRotatePolicy("audit", RotatePolicy{Frequency: RotateMonthly, Count: 12}))
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+
+	confs := renderLogrotateConfs(&cfg)
+	conf, ok := confs["/etc/logrotate.d/bin-myapp"]
+	if len(confs) != 1 || !ok {
+		t.Fatalf("Expected a single /etc/logrotate.d/bin-myapp file, got %v", confs)
+	}
+	if !strings.HasPrefix(conf, "/var/log/myapp/app.log /var/log/myapp/http.log {\n\trotate 8\n") {
+		t.Errorf("Expected app and http grouped in the first block, got:\n%s", conf)
+	}
+	if !strings.Contains(conf, "}\n\n/var/log/myapp/audit.log {\n\tmonthly\n") {
+		t.Errorf("Expected a separate audit block, got:\n%s", conf)
+	}
+	if n := strings.Count(conf, "sharedscripts"); n != 2 {
+		t.Errorf("Expected one sharedscripts per block, got %d", n)
+	}
+
+	cfg.SingleLogrotateFile = false
+	if confs = renderLogrotateConfs(&cfg); len(confs) != 3 || confs["/etc/logrotate.d/bin-myapp-http"] == "" {
+		t.Errorf("Expected one file per stream, got %v", confs)
+	}
+}
+
+// TestLogrotatePaths tests finding the installed logrotate files of a service
+func TestLogrotatePaths(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("bin-myapp-app", "/var/log/myapp/app.log {\n}\n")
+	write("bin-myapp-removed", "/var/log/myapp/removed.log {\n}\n")
+	write("bin-myapp-worker-app", "/var/log/myapp-worker/app.log {\n}\n")
+	write("other-app", "/var/log/myapp/app.log {\n}\n")
+
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "/var/log/myapp", WithStream("app", "app.log"))
+	paths, err := logrotatePaths(&cfg, dir)
+	if err != nil {
+		t.Fatalf("logrotatePaths failed: %v", err)
+	}
+	expected := []string{filepath.Join(dir, "bin-myapp"), filepath.Join(dir, "bin-myapp-app"),
+		filepath.Join(dir, "bin-myapp-removed")}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected paths %v, got %v", expected, paths)
+	}
+}
+
+// TestValidateLogrotateConf tests the logrotate dry run used for validation
+func TestValidateLogrotateConf(t *testing.T) {
+	dir := fakeCommands(t, map[string]string{"logrotate": `grep -q bogus "$4" && { echo 'error: unknown option bogus'; exit 1; }
+exit 0`})
+	var commands []Event
+	c := &ServiceConfig{events: func(e Event) {
+		if e.Type == EventCommand {
+			commands = append(commands, e)
+		}
+	}}
+
+	if err := validateLogrotateConf(c, "/var/log/app.log {\n\trotate 1\n}\n"); err != nil {
+		t.Errorf("Expected valid configuration, got %v", err)
+	}
+	if err := validateLogrotateConf(c, "/var/log/app.log {\n\tbogus\n}\n"); err == nil {
+		t.Error("Expected error for invalid configuration")
+	}
+	calls := fakeCalls(t, dir)
+	if !strings.Contains(calls, "logrotate --debug --state ") {
+		t.Errorf("Expected logrotate dry run with temporary state, got %q", calls)
+	}
+	if len(commands) != 2 || commands[0].Command[0] != "logrotate" || commands[1].Err == nil {
+		t.Errorf("Expected two logrotate command events, the second failed, got %+v", commands)
+	}
+
+	fakeCommands(t, map[string]string{"logrotate": "echo 'error: bad line'"})
+	if err := validateLogrotateConf(c, "x {\n}\n"); err == nil {
+		t.Error("Expected error reported on output to fail validation")
+	}
+}
@@ -0,0 +1,231 @@
+package systemd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// journalPriorityRe matches the priority filters accepted by journalctl -p:
+// a single priority or a FROM..TO range of names or numbers.
+var journalPriorityRe = regexp.MustCompile(
+	`^(emerg|alert|crit|err|warning|notice|info|debug|[0-7])(\.\.(emerg|alert|crit|err|warning|notice|info|debug|[0-7]))?$`)
+
+// maxJournalLine bounds a single JSON entry read from journalctl.
+const maxJournalLine = 16 << 20
+
+// LogOptions selects the journal entries returned by Manager.Logs.
+// The zero value returns the whole journal of the unit and exits.
+type LogOptions struct {
+	Since    time.Time // Only entries at or after this time
+	Until    time.Time // Only entries at or before this time
+	Lines    int       // Only the most recent N entries (0 for no limit)
+	Priority string    // journalctl priority filter, e.g. "err" or "warning..err"
+	Cursor   string    // Resume after the entry with this cursor
+	Follow   bool      // Keep streaming new entries until the context is cancelled
+}
+
+// JournalEntry is a journal entry of the service decoded from journalctl's JSON output.
+type JournalEntry struct {
+	Cursor     string            // Position of the entry, usable as LogOptions.Cursor
+	Time       time.Time         // Time the entry was received by journald
+	Message    string            // MESSAGE field
+	Priority   int               // Syslog priority (0-7, 6 when absent)
+	Identifier string            // SYSLOG_IDENTIFIER field
+	PID        int               // _PID of the logging process (0 when absent)
+	Unit       string            // _SYSTEMD_UNIT field
+	Hostname   string            // _HOSTNAME field
+	BootID     string            // _BOOT_ID field
+	Fields     map[string]string // All fields, including the ones above
+}
+
+// Logs returns the journal entries of the service selected by opts.
+//
+// Entries are delivered in order on the first channel, which is closed when
+// journalctl exits or ctx is cancelled. A failure is reported on the second
+// channel, which is closed afterwards; cancellation is not reported as an error.
+// With Follow set, new entries are streamed until ctx is cancelled.
+func (m *Manager) Logs(ctx context.Context, opts LogOptions) (<-chan JournalEntry, <-chan error) {
+	entries := make(chan JournalEntry)
+	errs := make(chan error, 1)
+
+	args, err := journalctlArgs(m.cfg, opts)
+	if err != nil {
+		close(entries)
+		errs <- err
+		close(errs)
+		return entries, errs
+	}
+
+	go func() {
+		defer close(errs)
+		defer close(entries)
+		if err := streamJournal(ctx, args, entries); err != nil && ctx.Err() == nil {
+			errs <- err
+		}
+	}()
+
+	return entries, errs
+}
+
+// journalctlArgs builds the journalctl arguments selecting the service's entries.
+func journalctlArgs(c *ServiceConfig, opts LogOptions) ([]string, error) {
+	unitFlag := "--unit"
+	if c.userScope {
+		unitFlag = "--user-unit"
+	}
+	args := []string{unitFlag, c.ServiceName, "--output", "json", "--no-pager"}
+	if c.LogNamespace != nil {
+		args = append(args, "--namespace", c.LogNamespace.Name)
+	}
+
+	if !opts.Since.IsZero() {
+		args = append(args, "--since", journalTime(opts.Since))
+	}
+	if !opts.Until.IsZero() {
+		if !opts.Since.IsZero() && opts.Until.Before(opts.Since) {
+			return nil, fmt.Errorf("log range ends (%s) before it starts (%s)", opts.Until, opts.Since)
+		}
+		args = append(args, "--until", journalTime(opts.Until))
+	}
+	if opts.Lines < 0 {
+		return nil, fmt.Errorf("invalid number of log lines %d", opts.Lines)
+	}
+	if opts.Lines > 0 {
+		args = append(args, "--lines", strconv.Itoa(opts.Lines))
+	}
+	if opts.Priority != "" {
+		if !journalPriorityRe.MatchString(opts.Priority) {
+			return nil, fmt.Errorf("invalid log priority filter %q", opts.Priority)
+		}
+		args = append(args, "--priority", opts.Priority)
+	}
+	if opts.Cursor != "" {
+		args = append(args, "--after-cursor", opts.Cursor)
+	}
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+
+	return args, nil
+}
+
+// journalTime formats t as a systemd timestamp, independent of the local time zone.
+func journalTime(t time.Time) string {
+	return fmt.Sprintf("@%d.%06d", t.Unix(), t.Nanosecond()/1000)
+}
+
+// streamJournal runs journalctl and sends the decoded entries until it exits or ctx is done.
+func streamJournal(ctx context.Context, args []string, entries chan<- JournalEntry) error {
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return newCommandError("journalctl", args, "", "", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxJournalLine)
+	var decodeErr error
+	for scanner.Scan() {
+		entry, err := decodeJournalEntry(scanner.Bytes())
+		if err != nil {
+			decodeErr = err
+			break
+		}
+		select {
+		case entries <- entry:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if decodeErr == nil {
+		decodeErr = scanner.Err()
+	}
+	if decodeErr != nil {
+		// Stop journalctl so Wait does not block on a full pipe
+		_ = cmd.Process.Kill()
+	}
+
+	if err := cmd.Wait(); err != nil && decodeErr == nil && ctx.Err() == nil {
+		return newCommandError("journalctl", args, "", stderr.String(), err)
+	}
+	return decodeErr
+}
+
+// decodeJournalEntry decodes one line of journalctl -o json output. Fields are
+// strings, byte arrays for binary values, arrays for repeated fields (the first
+// value is kept) or null for values too large to be shown.
+func decodeJournalEntry(line []byte) (JournalEntry, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return JournalEntry{}, fmt.Errorf("failed to decode journal entry: %w", err)
+	}
+
+	entry := JournalEntry{Priority: 6, Fields: make(map[string]string, len(raw))}
+	for k, v := range raw {
+		value, err := journalFieldValue(v)
+		if err != nil {
+			return JournalEntry{}, fmt.Errorf("failed to decode journal field %s: %w", k, err)
+		}
+		entry.Fields[k] = value
+	}
+
+	f := entry.Fields
+	entry.Cursor = f["__CURSOR"]
+	entry.Message = f["MESSAGE"]
+	entry.Identifier = f["SYSLOG_IDENTIFIER"]
+	entry.Unit = f["_SYSTEMD_UNIT"]
+	entry.Hostname = f["_HOSTNAME"]
+	entry.BootID = f["_BOOT_ID"]
+	if usec, err := strconv.ParseInt(f["__REALTIME_TIMESTAMP"], 10, 64); err == nil {
+		entry.Time = time.UnixMicro(usec)
+	}
+	if p, err := strconv.Atoi(f["PRIORITY"]); err == nil {
+		entry.Priority = p
+	}
+	if pid, err := strconv.Atoi(f["_PID"]); err == nil {
+		entry.PID = pid
+	}
+
+	return entry, nil
+}
+
+// journalFieldValue converts a JSON field value of journalctl's output to a string.
+func journalFieldValue(v json.RawMessage) (string, error) {
+	var s *string
+	if err := json.Unmarshal(v, &s); err == nil {
+		if s == nil {
+			return "", nil
+		}
+		return *s, nil
+	}
+
+	// Binary values are arrays of byte values
+	var b []byte
+	if err := json.Unmarshal(v, &b); err == nil {
+		return string(b), nil
+	}
+	var values []json.RawMessage
+	if err := json.Unmarshal(v, &values); err == nil {
+		if len(values) == 0 {
+			return "", nil
+		}
+		return journalFieldValue(values[0])
+	}
+
+	return "", errors.New("unsupported value " + string(v))
+}
@@ -0,0 +1,143 @@
+package systemd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestJournalctlArgs tests journalctl arguments built from LogOptions
+func TestJournalctlArgs(t *testing.T) {
+	cfg := &ServiceConfig{ServiceName: "myapp.service"}
+	since := time.Unix(1700000000, 250000000)
+
+	args, err := journalctlArgs(cfg, LogOptions{
+		Since:    since,
+		Until:    since.Add(time.Hour),
+		Lines:    50,
+		Priority: "warning..err",
+		Cursor:   "s=abc;i=1",
+		Follow:   true,
+	})
+	if err != nil {
+		t.Fatalf("journalctlArgs failed: %v", err)
+	}
+	expected := "--unit myapp.service --output json --no-pager --since @1700000000.250000 " +
+		"--until @1700003600.250000 --lines 50 --priority warning..err --after-cursor s=abc;i=1 --follow"
+	if got := strings.Join(args, " "); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	invalid := []LogOptions{
+		{Lines: -1},
+		{Priority: "loud"},
+		{Priority: "8"},
+		{Since: since, Until: since.Add(-time.Second)},
+	}
+	for _, opts := range invalid {
+		if _, err := journalctlArgs(cfg, opts); err == nil {
+			t.Errorf("Expected error for %+v", opts)
+		}
+	}
+}
+
+// TestDecodeJournalEntry tests decoding of journalctl JSON output
+func TestDecodeJournalEntry(t *testing.T) {
+	line := `{"__CURSOR":"s=1;i=2","__REALTIME_TIMESTAMP":"1700000000123456","MESSAGE":[104,105,10],` +
+		`"PRIORITY":"3","SYSLOG_IDENTIFIER":"myapp","_PID":"42","_SYSTEMD_UNIT":"myapp.service",` +
+		`"TAG":["first","second"],"BIG":null}`
+
+	entry, err := decodeJournalEntry([]byte(line))
+	if err != nil {
+		t.Fatalf("decodeJournalEntry failed: %v", err)
+	}
+	if entry.Cursor != "s=1;i=2" || entry.Message != "hi\n" || entry.Priority != 3 ||
+		entry.Identifier != "myapp" || entry.PID != 42 || entry.Unit != "myapp.service" {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+	if !entry.Time.Equal(time.UnixMicro(1700000000123456)) {
+		t.Errorf("Expected time %v, got %v", time.UnixMicro(1700000000123456), entry.Time)
+	}
+	if entry.Fields["TAG"] != "first" || entry.Fields["BIG"] != "" {
+		t.Errorf("Unexpected fields %v", entry.Fields)
+	}
+
+	if entry, err = decodeJournalEntry([]byte(`{"MESSAGE":"x"}`)); err != nil || entry.Priority != 6 {
+		t.Errorf("Expected default priority 6, got %d (%v)", entry.Priority, err)
+	}
+	if _, err := decodeJournalEntry([]byte(`not json`)); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
+
+// TestManagerLogs tests entries streamed from journalctl
+func TestManagerLogs(t *testing.T) {
+	dir := fakeCommands(t, map[string]string{
+		"journalctl": `echo '{"__CURSOR":"c1","MESSAGE":"one"}'
+echo '{"__CURSOR":"c2","MESSAGE":"two"}'`,
+	})
+	m := NewManager(&ServiceConfig{ServiceName: "myapp.service"})
+
+	entries, errs := m.Logs(context.Background(), LogOptions{Lines: 2})
+	var messages []string
+	for e := range entries {
+		messages = append(messages, e.Message)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Logs failed: %v", err)
+	}
+	if strings.Join(messages, ",") != "one,two" {
+		t.Errorf("Expected messages one,two, got %v", messages)
+	}
+	if calls := fakeCalls(t, dir); !strings.Contains(calls, "journalctl --unit myapp.service --output json --no-pager --lines 2") {
+		t.Errorf("Unexpected journalctl invocation %q", calls)
+	}
+}
+
+// TestManagerLogsFollow tests that following stops on context cancellation
+func TestManagerLogsFollow(t *testing.T) {
+	fakeCommands(t, map[string]string{
+		"journalctl": `echo '{"MESSAGE":"first"}'
+exec sleep 30`,
+	})
+	m := NewManager(&ServiceConfig{ServiceName: "myapp.service"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entries, errs := m.Logs(ctx, LogOptions{Follow: true})
+
+	select {
+	case e := <-entries:
+		if e.Message != "first" {
+			t.Errorf("Expected 'first', got %q", e.Message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for entry")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-entries:
+		if ok {
+			t.Error("Expected entries channel to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Entries channel not closed after cancellation")
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("Expected no error after cancellation, got %v", err)
+	}
+}
+
+// TestManagerLogsFailure tests journalctl failures reported on the error channel
+func TestManagerLogsFailure(t *testing.T) {
+	fakeCommands(t, map[string]string{"journalctl": "echo 'No journal files were found.' >&2; exit 1"})
+	m := NewManager(&ServiceConfig{ServiceName: "myapp.service"})
+
+	entries, errs := m.Logs(context.Background(), LogOptions{})
+	for range entries {
+	}
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "No journal files") {
+		t.Errorf("Expected journalctl error with output, got %v", err)
+	}
+}
@@ -1,10 +1,16 @@
 package systemd
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -32,35 +38,106 @@ type ServiceConfig struct {
 	ServiceLines  []string          // Additional lines to append to [Service] section
 	MakeLogrotate bool              // Whether to generate logrotate configuration
 	Streams       map[string]string // Map of stream names to log file names
+	Slice         *SliceConfig      // Slice unit to create and place the service in (optional)
+
+	// Log rotation
+	RotatePolicy         *RotatePolicy           // Rotation policy of all streams (defaults to DefaultRotatePolicy)
+	StreamRotatePolicies map[string]RotatePolicy // Per-stream rotation policies overriding RotatePolicy
+	SingleLogrotateFile  bool                    // Render all streams into /etc/logrotate.d/<UniqueName>
+	LogDirSetup          *LogDirConfig           // Mode, ownership and ACLs of LogDir (optional)
+
+	// Paths declared in tmpfiles.d
+	TmpfilesEntries []TmpfilesEntry // Runtime, state and cache paths created by systemd-tmpfiles
+
+	// Log stream routing
+	ProgramName  string       // Syslog identifier of the service (defaults to the binary name)
+	StreamFilter StreamFilter // Property used to select the service's messages in rsyslog
+	StreamMatch  StreamMatch  // How the stream=<name> field is matched within a message
+
+	// Log stream formats
+	LogFormat     StreamFormat            // Default output format of all streams (raw message)
+	StreamFormats map[string]StreamFormat // Per-stream output formats overriding LogFormat
+
+	// Remote log forwarding
+	Forwards       []ForwardTarget            // Collectors receiving every stream
+	StreamForwards map[string][]ForwardTarget // Collectors receiving a single stream
+
+	// Service account
+	Account     *AccountConfig // Additional account settings (optional)
+	UseSysusers bool           // Declare the account via sysusers.d instead of useradd/groupadd
+	DynamicUser bool           // Run under a transient systemd-allocated user (no account is created)
+
+	// Journald settings
+	LogNamespace *JournalNamespace // Isolated journal of the service (optional)
+
+	errs      []error     // Validation errors recorded by options
+	userScope bool        // Managed by the user's service manager (see WithUserScope)
+	events    func(Event) // Reports events to the Manager (set by NewManager)
+}
+
+// Validate reports configuration errors recorded while applying options,
+// such as out-of-range resource values. Install refuses to run on an invalid
+// configuration.
+func (c *ServiceConfig) Validate() error {
+	errs := append([]error(nil), c.errs...)
+	errs = append(errs, validateStreams(c)...)
+	errs = append(errs, validateFormats(c)...)
+	errs = append(errs, validateForwards(c)...)
+	errs = append(errs, validateRotatePolicies(c)...)
+	errs = append(errs, validateSlice(c)...)
+	return errors.Join(errs...)
+}
+
+// invalid records a validation error for the given directive.
+func (c *ServiceConfig) invalid(directive string, err error) {
+	c.errs = append(c.errs, fmt.Errorf("%s: %w", directive, err))
 }
 
 // Manager handles installation and management of systemd services.
 // It provides thread-safe operations for service lifecycle management
 // with optional channel-based logging and error reporting.
 type Manager struct {
-	cfg      *ServiceConfig
-	errChan  chan<- error
-	infoChan chan<- string
+	cfg           *ServiceConfig
+	errChan       chan<- error
+	infoChan      chan<- string
+	linger        bool
+	useDBus       bool
+	busAddress    string
+	watchInterval time.Duration
+	eventChan     chan<- Event
+	eventHandler  func(Event)
+	observers     []Observer
+	blocking      bool // Channel sends wait for sendTimeout, see WithBlockingDelivery
+	sendTimeout   time.Duration
+	dropped       atomic.Uint64 // Messages not delivered to full channels
+
+	mu        sync.Mutex // Guards the step in progress
+	step      Step
+	stepIndex int
+	stepTotal int
 }
 
 // Option is a functional option for configuring Manager behavior.
 type Option func(*Manager)
 
 // WithErrorChan configures the Manager to send errors to the specified channel.
-// Errors are sent non-blocking - if the channel is full, the error is dropped.
+// Errors are sent non-blocking - if the channel is full, the error is dropped and
+// counted by Dropped, unless WithBlockingDelivery is set.
 func WithErrorChan(ch chan<- error) Option {
 	return func(m *Manager) { m.errChan = ch }
 }
 
 // WithInfoChan configures the Manager to send informational messages to the specified channel.
-// Messages are sent non-blocking - if the channel is full, the message is dropped.
+// Messages are sent non-blocking - if the channel is full, the message is dropped and
+// counted by Dropped, unless WithBlockingDelivery is set.
 func WithInfoChan(ch chan<- string) Option {
 	return func(m *Manager) { m.infoChan = ch }
 }
 
 // NewManager creates a new service Manager with the given configuration and options.
 //
-// If cfg.SystemdFile is empty, it defaults to /etc/systemd/system/<ServiceName>.
+// If cfg.SystemdFile is empty, it defaults to /etc/systemd/system/<ServiceName>,
+// or ~/.config/systemd/user/<ServiceName> with WithUserScope.
 // If cfg.MakeLogrotate is true but cfg.LogDir is empty, MakeLogrotate is automatically disabled.
 //
 // The configuration is copied into the Manager, so subsequent modifications to the
@@ -69,180 +146,348 @@ func NewManager(cfg *ServiceConfig, opts ...Option) *Manager {
 	// Create a copy to avoid external modifications
 	configCopy := *cfg
 
-	// Set default SystemdFile path if not specified
-	if configCopy.SystemdFile == "" {
-		configCopy.SystemdFile = fmt.Sprintf("/etc/systemd/system/%s", configCopy.ServiceName)
-	}
-
-	// Disable logrotate if no log directory is specified
-	if configCopy.MakeLogrotate && configCopy.LogDir == "" {
-		configCopy.MakeLogrotate = false
-	}
-
 	m := &Manager{cfg: &configCopy}
+	configCopy.events = m.emit
 
 	// Apply functional options
 	for _, opt := range opts {
 		opt(m)
 	}
 
+	// Set default SystemdFile path if not specified. The system default set by
+	// NewServiceConfig is replaced by the user unit directory in user scope.
+	systemUnit := fmt.Sprintf("/etc/systemd/system/%s", configCopy.ServiceName)
+	if configCopy.userScope && (configCopy.SystemdFile == "" || configCopy.SystemdFile == systemUnit) {
+		configCopy.SystemdFile = ""
+		if dir, err := userUnitDir(); err == nil {
+			configCopy.SystemdFile = filepath.Join(dir, configCopy.ServiceName)
+		}
+	} else if configCopy.SystemdFile == "" {
+		configCopy.SystemdFile = systemUnit
+	}
+
+	// Disable logrotate if no log directory is specified
+	if configCopy.MakeLogrotate && configCopy.LogDir == "" {
+		configCopy.MakeLogrotate = false
+	}
+
 	return m
 }
 
 // Install performs complete service installation including user creation,
 // configuration file generation, and service activation.
 //
-// The installation process:
-//  1. Creates system user and group if they don't exist
-//  2. Generates rsyslog configuration (if LogDir is specified)
-//  3. Generates logrotate configuration (if MakeLogrotate is enabled)
-//  4. Creates systemd unit file
-//  5. Reloads systemd daemon configuration
-//  6. Enables and starts the service
+// The installation runs these steps, reported as events (see WithEventChan):
+//  1. validate: validates the configuration
+//  2. preflight: checks for root privileges (skipped in user scope)
+//  3. account: creates system group and user if they don't exist, or applies
+//     sysusers.d (skipped for DynamicUser services). In user scope it creates the
+//     user unit directory and enables lingering if requested
+//  4. tmpfiles: writes and applies tmpfiles.d configuration (if paths are declared)
+//  5. logdir: provisions LogDir (if LogDir is specified)
+//  6. rsyslog: generates, validates and activates rsyslog configuration (if LogDir is specified)
+//  7. logrotate: validates and writes logrotate configuration (if MakeLogrotate is enabled)
+//  8. journald: writes the journald namespace configuration (if a namespace is configured)
+//  9. slice: creates the slice unit file (if a slice is configured)
+//  10. unit: creates systemd unit file
+//  11. connect: connects to the service manager
+//  12. reload: reloads systemd daemon configuration
+//  13. enable: enables the service
+//  14. start: starts the service, returning *JobError if the start job fails
 //
-// Any failure during installation will halt the process and return an error.
+// Steps 4-8 need root and are skipped in user scope.
+//
+// Any failure during installation will halt the process and return a *StepError
+// naming the failed step; without root privileges it fails with ErrNotRoot
+// (except in user scope).
 // Partial installations may leave configuration files that should be cleaned
 // up using Uninstall().
 func (m *Manager) Install() error {
 	c := m.cfg
 	m.infof("Installing service: %s", c.ServiceName)
 
-	// Ensure system user and group exist
-	if err := ensureServiceUser(c.User, c.Group); err != nil {
-		return m.fail(err)
-	}
-	m.infof("Service user and group ensured")
-
-	// Configure logging if LogDir is specified
-	if c.LogDir != "" {
-		if err := writeRsyslogConf(c); err != nil {
-			return m.fail(err)
+	var b backend
+	defer func() {
+		if b != nil {
+			_ = b.close()
 		}
-		m.infof("Rsyslog configuration written")
-
-		if c.MakeLogrotate {
+	}()
+
+	system := !c.userScope
+	logging := c.LogDir != "" && system
+	steps := []step{
+		{StepValidate, "", func() error {
+			err := c.Validate()
+			if err == nil && c.userScope {
+				err = checkUserScope(c)
+			}
+			if err != nil {
+				return fmt.Errorf("invalid service configuration: %w", err)
+			}
+			return nil
+		}},
+		{StepPreflight, skipUnless(system, "user scope"), checkRoot},
+		{StepAccount, skipUnless(!c.DynamicUser || c.userScope, "dynamic user enabled"), m.installAccount},
+		{StepTmpfiles, skipUnless(usesTmpfiles(c) && system, "no tmpfiles.d entries"), func() error {
+			if err := writeTmpfilesConf(c); err != nil {
+				return err
+			}
+			m.infof("Tmpfiles configuration applied: %s", tmpfilesPath(c))
+			return nil
+		}},
+		{StepLogDir, skipUnless(logging, "no log directory"), m.provisionLogDir},
+		{StepRsyslog, skipUnless(logging, "no log directory"), func() error {
+			if err := writeRsyslogConf(c); err != nil {
+				return err
+			}
+			m.infof("Rsyslog configuration written")
+			m.probeForwards()
+			return nil
+		}},
+		{StepLogrotate, skipUnless(logging && c.MakeLogrotate, "logrotate disabled"), func() error {
 			if err := writeLogrotateConfs(c); err != nil {
-				return m.fail(err)
+				return err
 			}
 			m.infof("Logrotate configurations written")
-		}
-	}
-
-	// Create systemd unit file
-	if err := writeSystemdUnit(c); err != nil {
-		return m.fail(err)
+			return nil
+		}},
+		{StepJournald, skipUnless(c.LogNamespace != nil && system, "no journal namespace"), func() error {
+			if err := writeJournaldConf(c); err != nil {
+				return err
+			}
+			m.infof("Journald configuration written for namespace %s", c.LogNamespace.Name)
+			return nil
+		}},
+		{StepSlice, skipUnless(c.Slice != nil, "no slice"), func() error {
+			if err := writeSliceUnit(c); err != nil {
+				return err
+			}
+			m.infof("Slice unit file written: %s", c.Slice.UnitName())
+			return nil
+		}},
+		{StepUnit, "", func() error {
+			if err := writeSystemdUnit(c); err != nil {
+				return err
+			}
+			m.infof("Systemd unit file written")
+			return nil
+		}},
+		{StepConnect, "", func() (err error) {
+			b, err = m.openBackend()
+			return err
+		}},
+		{StepReload, "", func() error {
+			if err := b.reload(); err != nil {
+				return err
+			}
+			m.infof("Systemd daemon configuration reloaded")
+			return nil
+		}},
+		{StepEnable, "", func() error { return b.enable(c.ServiceName) }},
+		{StepStart, "", func() error {
+			if _, err := m.runJob(b, "start"); err != nil {
+				return err
+			}
+			m.infof("Service enabled and started successfully")
+			return nil
+		}},
 	}
-	m.infof("Systemd unit file written")
 
-	// Reload systemd configuration
-	if err := execCommand("systemctl", "daemon-reload"); err != nil {
-		return m.fail(err)
-	}
-	m.infof("Systemd daemon configuration reloaded")
+	return m.runSteps(steps)
+}
 
-	// Enable and start the service
-	if err := execCommand("systemctl", "enable", "--now", c.ServiceName); err != nil {
-		return m.fail(err)
+// installAccount ensures the account the service runs as.
+func (m *Manager) installAccount() error {
+	c := m.cfg
+	switch {
+	case c.userScope:
+		return m.installUserScope()
+	case c.UseSysusers:
+		if err := writeSysusersConf(c); err != nil {
+			return err
+		}
+		m.infof("Sysusers configuration applied")
+	default:
+		if err := ensureServiceAccount(c); err != nil {
+			return err
+		}
+		m.infof("Service user and group ensured")
 	}
-	m.infof("Service enabled and started successfully")
-
 	return nil
 }
 
 // Uninstall removes the service and cleans up all associated configuration files.
 //
-// The uninstallation process:
-//  1. Disables the service (ignores errors)
-//  2. Stops the service (ignores errors)
-//  3. Removes systemd unit file
-//  4. Removes rsyslog configuration
-//  5. Removes logrotate configuration files
-//  6. Reloads systemd daemon configuration
+// The uninstallation runs these steps, reported as events (see WithEventChan):
+//  1. preflight: checks for root privileges (skipped in user scope)
+//  2. connect: connects to the service manager
+//  3. disable: disables the service (ignores errors)
+//  4. stop: stops the service (ignores errors)
+//  5. remove: removes the systemd unit, rsyslog, logrotate, sysusers.d and
+//     tmpfiles.d configuration files (accounts and paths are kept)
+//  6. slice: removes the slice unit file if no other unit references it
+//  7. journald: removes the journald namespace configuration if no other unit uses the namespace
+//  8. rsyslog: restarts rsyslog if streams were configured (ignores errors)
+//  9. reload: reloads systemd daemon configuration
 //
 // File removal operations are best-effort - missing files are ignored.
-// Only the final daemon-reload operation can return an error.
+// Only the privilege check, the connection and the final daemon-reload can
+// return an error, as *StepError.
 func (m *Manager) Uninstall() error {
 	c := m.cfg
 	m.infof("Uninstalling service: %s", c.ServiceName)
 
-	// Best-effort service shutdown
-	_ = execCommand("systemctl", "disable", c.ServiceName)
-	_ = execCommand("systemctl", "stop", c.ServiceName)
+	var b backend
+	defer func() {
+		if b != nil {
+			_ = b.close()
+		}
+	}()
+
+	system := !c.userScope
+	steps := []step{
+		{StepPreflight, skipUnless(system, "user scope"), checkRoot},
+		{StepConnect, "", func() (err error) {
+			b, err = m.openBackend()
+			return err
+		}},
+		// Best-effort service shutdown
+		{StepDisable, "", func() error {
+			_ = b.disable(c.ServiceName)
+			return nil
+		}},
+		{StepStop, "", func() error {
+			_, _ = b.job("stop", c.ServiceName)
+			return nil
+		}},
+		{StepRemove, "", func() error {
+			m.removeFiles()
+			return nil
+		}},
+		// Remove the slice unless another unit still lives in it
+		{StepSlice, skipUnless(c.Slice != nil, "no slice"), func() error {
+			m.removeSlice()
+			return nil
+		}},
+		// Remove the journal namespace configuration unless another unit still uses it
+		{StepJournald, skipUnless(c.LogNamespace != nil && system, "no journal namespace"), func() error {
+			m.removeJournaldConf()
+			return nil
+		}},
+		// Reload rsyslog so removed stream rules stop applying
+		{StepRsyslog, skipUnless(c.LogDir != "" && len(c.Streams) > 0 && system, "no streams"), func() error {
+			if err := c.execCommand("systemctl", "try-restart", "rsyslog.service"); err != nil {
+				m.error(err)
+			} else {
+				m.infof("Rsyslog restarted")
+			}
+			return nil
+		}},
+		{StepReload, "", func() error {
+			if err := b.reload(); err != nil {
+				return err
+			}
+			m.infof("Systemd daemon configuration reloaded")
+			return nil
+		}},
+	}
 
-	// Clean up configuration files
-	filesToRemove := []string{
-		c.SystemdFile,
-		rsyslogPath(c),
-		logrotateCorePath(c) + "-*", // Glob pattern for logrotate files
+	return m.runSteps(steps)
+}
+
+// removeFiles removes the configuration files of the service. Failures are
+// reported on the error channel and otherwise ignored.
+func (m *Manager) removeFiles() {
+	c := m.cfg
+	filesToRemove := []string{c.SystemdFile}
+	if !c.userScope {
+		filesToRemove = append(filesToRemove, rsyslogPath(c))
+		if c.LogDir != "" {
+			paths, err := logrotatePaths(c, logrotateDir)
+			if err != nil {
+				m.error(fmt.Errorf("failed to find logrotate configs: %w", err))
+				paths = []string{logrotateCorePath(c)}
+			}
+			filesToRemove = append(filesToRemove, paths...)
+		}
+		if usesTmpfiles(c) {
+			filesToRemove = append(filesToRemove, tmpfilesPath(c))
+		}
+		if c.UseSysusers {
+			filesToRemove = append(filesToRemove, sysusersPath(c))
+		}
 	}
 
 	for _, path := range filesToRemove {
-		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		if err := c.removeFile(path); err != nil && !os.IsNotExist(err) {
 			m.error(err)
 		} else {
 			m.infof("Removed: %s", path)
 		}
 	}
 
-	// Reload systemd configuration
-	if err := execCommand("systemctl", "daemon-reload"); err != nil {
-		return m.fail(err)
+	// Unload omrelp once no remaining fragment forwards over RELP
+	if !c.userScope {
+		if removed, err := removeOmrelp(c, rsyslogConfDir); err != nil {
+			m.error(fmt.Errorf("failed to check references to omrelp: %w", err))
+		} else if removed {
+			m.infof("Removed: %s", filepath.Join(rsyslogConfDir, omrelpModuleFile))
+		}
 	}
-	m.infof("Systemd daemon configuration reloaded")
+}
 
-	return nil
+// removeSlice removes the configured slice unit file when no remaining unit file
+// references it. Failures are reported on the error channel and otherwise ignored.
+func (m *Manager) removeSlice() {
+	c := m.cfg
+	name := c.Slice.UnitName()
+
+	inUse, err := sliceInUse(filepath.Dir(c.SystemdFile), name)
+	if err != nil {
+		m.error(fmt.Errorf("failed to check references to slice %s: %w", name, err))
+		return
+	}
+	if inUse {
+		m.infof("Slice %s still in use, keeping it", name)
+		return
+	}
+
+	if err := c.removeFile(slicePath(c)); err != nil && !os.IsNotExist(err) {
+		m.error(err)
+	} else {
+		m.infof("Removed: %s", slicePath(c))
+	}
 }
 
-// infof sends a formatted informational message to the info channel if configured.
-// The send operation is non-blocking - if the channel is full, the message is dropped.
+// infof sends a formatted informational message to the info channel and event
+// listeners if configured.
 func (m *Manager) infof(format string, v ...interface{}) {
-	if m.infoChan == nil {
+	if !m.listening() {
 		return
 	}
-	msg := fmt.Sprintf(format, v...)
-	select {
-	case m.infoChan <- msg:
-	default: // Non-blocking send
-	}
+	m.emit(Event{Type: EventInfo, Message: fmt.Sprintf(format, v...)})
 }
 
-// error sends an error to the error channel if configured.
-// The send operation is non-blocking - if the channel is full, the error is dropped.
+// error sends an error to the error channel and event listeners if configured.
 func (m *Manager) error(err error) {
-	if m.errChan == nil || err == nil {
+	if err == nil {
 		return
 	}
-	select {
-	case m.errChan <- err:
-	default: // Non-blocking send
-	}
+	m.emit(Event{Type: EventWarning, Message: err.Error(), Err: err})
 }
 
-// fail sends an error to the error channel and returns it.
-// This is a convenience function for error handling in installation/uninstallation.
-func (m *Manager) fail(err error) error {
-	m.error(err)
-	return err
+// failStep wraps err in a StepError for step, sends it to the error channel and returns it.
+func (m *Manager) failStep(step Step, err error) error {
+	stepErr := &StepError{Step: step, Err: err}
+	m.emit(Event{Type: EventStepFailed, Message: stepErr.Error(), Err: stepErr})
+	return stepErr
 }
 
-// ensureServiceUser creates the specified system user and group if they don't exist.
-// Both user and group are created as system accounts with no home directory.
-func ensureServiceUser(user, group string) error {
-	// Check if user exists, create if not
-	if _, err := execOutput("id", "-u", user); err != nil {
-		if err := execCommand("useradd", "--system", "--no-create-home",
-			"--shell", "/usr/sbin/nologin", user); err != nil {
-			return fmt.Errorf("failed to create user %s: %w", user, err)
-		}
-	}
-
-	// Check if group exists, create if not
-	if _, err := execOutput("getent", "group", group); err != nil {
-		if err := execCommand("groupadd", "--system", group); err != nil {
-			return fmt.Errorf("failed to create group %s: %w", group, err)
-		}
+// checkRoot returns ErrNotRoot unless the process runs with root privileges.
+func checkRoot() error {
+	if os.Geteuid() != 0 {
+		return ErrNotRoot
 	}
-
 	return nil
 }
 
@@ -256,6 +501,18 @@ func writeSystemdUnit(c *ServiceConfig) error {
 		extraLines = strings.Join(c.ServiceLines, "\n") + "\n"
 	}
 
+	// User managers run services as the calling user and have no multi-user.target.
+	// Dynamic users are allocated by systemd; a static account of the same name
+	// would be used instead.
+	account := fmt.Sprintf("User=%s\nGroup=%s\n", c.User, c.Group)
+	target := "multi-user.target"
+	if c.userScope || c.DynamicUser {
+		account = ""
+	}
+	if c.userScope {
+		target = "default.target"
+	}
+
 	// Generate the complete unit file content
 	unit := fmt.Sprintf(`[Unit]
 Description=%s
@@ -265,100 +522,29 @@ After=network.target
 Type=notify
 ExecStart=%s
 Restart=on-failure
-User=%s
-Group=%s
-%s[Install]
-WantedBy=multi-user.target
-`, c.UniqueName, c.BinaryPath, c.User, c.Group, extraLines)
+%s%s[Install]
+WantedBy=%s
+`, c.UniqueName, c.BinaryPath, account, extraLines, target)
 
-	return os.WriteFile(c.SystemdFile, []byte(unit), configFileMode) // #nosec G306
+	return c.writeFile(c.SystemdFile, []byte(unit))
 }
 
-// writeRsyslogConf creates an rsyslog configuration file for log stream routing.
-// This configuration enables structured logging by routing messages containing
-// 'stream=<name>' to specific log files with proper ownership and permissions.
-func writeRsyslogConf(c *ServiceConfig) error {
-	if len(c.Streams) == 0 {
-		return nil // No streams configured
-	}
-
-	var configs []string
-	for streamName, fileName := range c.Streams {
-		streamConfig := fmt.Sprintf(`if $msg contains 'stream=%s' then {
-	action(type="omfile" file="%s/%s" template="%s"
-         dirCreateMode="0750" dirOwner="%s" dirGroup="%s"
-		 fileCreateMode="0640" fileOwner="%s" fileGroup="%s")
-	stop
-}`, streamName, c.LogDir, fileName, c.UniqueName, c.User, c.Group, c.User, c.Group)
-		configs = append(configs, streamConfig)
-	}
-
-	// Generate complete rsyslog configuration
-	conf := fmt.Sprintf(`module(load="imuxsock")
-module(load="imklog")
-module(load="omfile")
-template(name="%s" type="string" string="%%msg%%\n")
-%s`, c.UniqueName, strings.Join(configs, "\n"))
-
-	return os.WriteFile(rsyslogPath(c), []byte(conf), configFileMode) // #nosec G306
-}
-
-// writeLogrotateConfs creates logrotate configuration files for each log stream.
-// Each stream gets its own logrotate configuration with weekly rotation,
-// compression, and automatic cleanup of old log files.
-func writeLogrotateConfs(c *ServiceConfig) error {
-	if !c.MakeLogrotate || c.Streams == nil {
-		return nil
-	}
-
-	for streamName, fileName := range c.Streams {
-		logrotateConfig := fmt.Sprintf(`%s/%s {
-	weekly
-	rotate 8
-	size 100M
-	compress
-	delaycompress
-	missingok
-	notifempty
-	create 0640 %s %s
-	sharedscripts
-	postrotate
-		systemctl kill -s HUP rsyslog.service
-	endscript
-}`, c.LogDir, fileName, c.User, c.Group)
-
-		configPath := logrotateCorePath(c) + "-" + streamName
-		if err := os.WriteFile(configPath, []byte(logrotateConfig), configFileMode); err != nil { // #nosec G306
-			return fmt.Errorf("failed to write logrotate config for stream %s: %w", streamName, err)
-		}
+// runCommand executes a command and returns its standard output and error.
+// Failures are returned as *CommandError.
+func runCommand(cmd string, args ...string) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	c := exec.Command(cmd, args...)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return stdout.Bytes(), stderr.Bytes(), newCommandError(cmd, args, stdout.String(), stderr.String(), err)
 	}
-
-	return nil
-}
-
-// rsyslogPath returns the file path for the rsyslog configuration.
-func rsyslogPath(c *ServiceConfig) string {
-	return fmt.Sprintf("/etc/rsyslog.d/%s.conf", c.UniqueName)
-}
-
-// logrotateCorePath returns the base file path for logrotate configurations.
-// Individual stream configurations append "-{streamname}" to this path.
-func logrotateCorePath(c *ServiceConfig) string {
-	return fmt.Sprintf("/etc/logrotate.d/%s", c.UniqueName)
-}
-
-// execOutput executes a command and returns its combined stdout/stderr output.
-func execOutput(cmd string, args ...string) ([]byte, error) {
-	return exec.Command(cmd, args...).CombinedOutput()
+	return stdout.Bytes(), stderr.Bytes(), nil
 }
 
 // execCommand executes a command and returns an error if it fails.
-// The error includes both the exit status and any output for debugging.
+// Failures are returned as *CommandError carrying the exit status and output.
 func execCommand(cmd string, args ...string) error {
-	out, err := execOutput(cmd, args...)
-	if err != nil {
-		return fmt.Errorf("command '%s %s' failed: %w\nOutput: %s",
-			cmd, strings.Join(args, " "), err, string(out))
-	}
-	return nil
+	_, _, err := runCommand(cmd, args...)
+	return err
 }
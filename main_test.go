@@ -380,6 +380,31 @@ func TestConcurrentFileOperations(t *testing.T) {
 	}
 }
 
+// fakeCommands installs shell scripts named after system commands into a temporary
+// directory placed first in PATH, so command invocations can be tested without root.
+func fakeCommands(t *testing.T, scripts map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, body := range scripts {
+		script := "#!/bin/sh\necho \"" + name + " $*\" >> " + filepath.Join(dir, "calls.log") + "\n" + body + "\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+// fakeCalls returns the commands recorded by fakeCommands.
+func fakeCalls(t *testing.T, dir string) string {
+	t.Helper()
+	calls, err := os.ReadFile(filepath.Join(dir, "calls.log"))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(calls)
+}
+
 // Benchmark tests for performance analysis
 func BenchmarkNewManager(b *testing.B) {
 	cfg := ServiceConfig{
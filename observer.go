@@ -0,0 +1,147 @@
+package systemd
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Observer receives the progress of Manager operations through synchronous
+// callbacks. OnEvent is called for every event; OnInfo and OnError receive the
+// messages and errors also sent to WithInfoChan and WithErrorChan. Callbacks
+// run on the goroutine of the operation and must not call back into the Manager.
+type Observer interface {
+	OnEvent(e Event)
+	OnInfo(msg string)
+	OnError(err error)
+}
+
+// WithObserver registers an Observer. It may be given several times.
+func WithObserver(o Observer) Option {
+	return func(m *Manager) {
+		if o != nil {
+			m.observers = append(m.observers, o)
+		}
+	}
+}
+
+// WithLogger logs every event to logger: failures at error level, warnings and
+// failed commands at warn level, commands and finished steps at debug level and
+// everything else at info level.
+func WithLogger(logger *slog.Logger) Option {
+	if logger == nil {
+		return func(*Manager) {}
+	}
+	return WithObserver(&slogObserver{logger: logger})
+}
+
+// WithBlockingDelivery makes sends to the channels of WithErrorChan,
+// WithInfoChan and WithEventChan wait up to timeout for a receiver when the
+// channel is full, instead of dropping the message at once. A timeout of zero
+// or less waits indefinitely. Messages still undelivered are counted by Dropped.
+func WithBlockingDelivery(timeout time.Duration) Option {
+	return func(m *Manager) {
+		m.blocking = true
+		m.sendTimeout = timeout
+	}
+}
+
+// Dropped returns the number of messages and events that could not be sent to
+// the configured channels because they were full.
+func (m *Manager) Dropped() uint64 {
+	return m.dropped.Load()
+}
+
+// listening reports whether anything receives the Manager's events.
+func (m *Manager) listening() bool {
+	return m.infoChan != nil || m.errChan != nil || m.eventChan != nil ||
+		m.eventHandler != nil || len(m.observers) > 0
+}
+
+// deliver sends v to ch according to the delivery mode of m, counting the
+// values that could not be sent.
+func deliver[T any](m *Manager, ch chan<- T, v T) {
+	if ch == nil {
+		return
+	}
+	select {
+	case ch <- v:
+		return
+	default:
+	}
+
+	if m.blocking {
+		if m.sendTimeout <= 0 {
+			ch <- v
+			return
+		}
+		timer := time.NewTimer(m.sendTimeout)
+		defer timer.Stop()
+		select {
+		case ch <- v:
+			return
+		case <-timer.C:
+		}
+	}
+	m.dropped.Add(1)
+}
+
+// slogObserver logs events to a slog.Logger.
+type slogObserver struct {
+	logger *slog.Logger
+}
+
+// OnEvent logs the event with its step, file, command and error attributes.
+func (o *slogObserver) OnEvent(e Event) {
+	level := slog.LevelInfo
+	switch e.Type {
+	case EventStepFailed:
+		level = slog.LevelError
+	case EventWarning:
+		level = slog.LevelWarn
+	case EventCommand:
+		level = slog.LevelDebug
+		if e.Err != nil {
+			level = slog.LevelWarn
+		}
+	case EventStepFinished:
+		level = slog.LevelDebug
+	}
+
+	ctx := context.Background()
+	if !o.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{slog.String("event", string(e.Type))}
+	if e.Step != "" {
+		attrs = append(attrs, slog.String("step", string(e.Step)), slog.Int("index", e.Index), slog.Int("total", e.Total))
+	}
+	if e.Path != "" {
+		attrs = append(attrs, slog.String("path", e.Path))
+	}
+	if e.Type == EventFileWritten {
+		attrs = append(attrs, slog.Int("added", e.LinesAdded), slog.Int("removed", e.LinesRemoved))
+	}
+	if e.Duration > 0 {
+		attrs = append(attrs, slog.Duration("duration", e.Duration))
+	}
+	if e.Err != nil {
+		attrs = append(attrs, slog.Any("err", e.Err))
+	}
+
+	msg := e.Message
+	if msg == "" {
+		msg = string(e.Type)
+		if e.Step != "" {
+			msg += " " + string(e.Step)
+		}
+	}
+	o.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// OnInfo does nothing; info messages are logged by OnEvent.
+func (o *slogObserver) OnInfo(string) {}
+
+// OnError does nothing; errors are logged by OnEvent.
+func (o *slogObserver) OnError(error) {}
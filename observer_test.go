@@ -0,0 +1,118 @@
+package systemd
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingObserver records the callbacks it receives
+type recordingObserver struct {
+	events []Event
+	infos  []string
+	errs   []error
+}
+
+func (o *recordingObserver) OnEvent(e Event)   { o.events = append(o.events, e) }
+func (o *recordingObserver) OnInfo(msg string) { o.infos = append(o.infos, msg) }
+func (o *recordingObserver) OnError(err error) { o.errs = append(o.errs, err) }
+
+// TestObserver tests the synchronous observer callbacks
+func TestObserver(t *testing.T) {
+	cfg := NewServiceConfig("testuser", "testgroup", "/usr/bin/test", "", WithCPUWeight(0))
+	o := &recordingObserver{}
+	m := NewManager(&cfg, WithObserver(o))
+
+	m.infof("hello %s", "world")
+	err := m.Install()
+	if err == nil {
+		t.Fatal("Expected Install to fail")
+	}
+
+	if len(o.infos) != 2 || o.infos[0] != "hello world" || o.infos[1] != "Installing service: bin-test.service" {
+		t.Errorf("Expected two info messages, got %v", o.infos)
+	}
+	var stepErr *StepError
+	if len(o.errs) != 1 || !errors.As(o.errs[0], &stepErr) || stepErr.Step != StepValidate {
+		t.Errorf("Expected validate step error, got %v", o.errs)
+	}
+	if len(o.events) != 4 || o.events[0].Type != EventInfo ||
+		o.events[2].Type != EventStepStarted || o.events[3].Type != EventStepFailed {
+		t.Errorf("Expected info, step-started and step-failed events, got %+v", o.events)
+	}
+}
+
+// TestWithLogger tests that events are logged at the level matching their type
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"}, WithLogger(logger))
+
+	m.setStep(StepReload, 11, 14)
+	m.infof("reloading")
+	m.emit(Event{Type: EventCommand, Message: "systemctl daemon-reload"})
+	m.emit(Event{Type: EventStepFinished})
+	m.setStep("", 0, 0)
+	m.error(errors.New("disk almost full"))
+
+	// A failing step is logged as an error
+	cfg := NewServiceConfig("app", "app", "/usr/bin/app", "", WithCPUWeight(0))
+	if err := NewManager(&cfg, WithLogger(logger)).Install(); err == nil {
+		t.Fatal("Expected Install to fail")
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`level=INFO msg=reloading event=info step=reload index=11 total=14`,
+		`level=WARN msg="disk almost full" event=warning err="disk almost full"`,
+		`level=ERROR msg="validate: invalid service configuration: CPUWeight: value 0 out of range 1..10000" event=step-failed step=validate`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in log output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "daemon-reload") || strings.Contains(out, "step-finished") {
+		t.Errorf("Expected debug events to be filtered out:\n%s", out)
+	}
+}
+
+// TestDroppedMessages tests that messages sent to full channels are counted
+func TestDroppedMessages(t *testing.T) {
+	infoChan := make(chan string, 1)
+	m := NewManager(&ServiceConfig{}, WithInfoChan(infoChan))
+
+	m.infof("first")
+	m.infof("second")
+	m.infof("third")
+	if m.Dropped() != 2 {
+		t.Errorf("Expected 2 dropped messages, got %d", m.Dropped())
+	}
+	if msg := <-infoChan; msg != "first" {
+		t.Errorf("Expected first message, got %q", msg)
+	}
+}
+
+// TestBlockingDelivery tests that blocking delivery waits for a receiver
+func TestBlockingDelivery(t *testing.T) {
+	infoChan := make(chan string)
+	m := NewManager(&ServiceConfig{}, WithInfoChan(infoChan), WithBlockingDelivery(time.Second))
+
+	received := make(chan string)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		received <- <-infoChan
+	}()
+	m.infof("slow reader")
+	if msg := <-received; msg != "slow reader" || m.Dropped() != 0 {
+		t.Errorf("Expected delivered message without drops, got %q and %d drops", msg, m.Dropped())
+	}
+
+	m = NewManager(&ServiceConfig{}, WithInfoChan(infoChan), WithBlockingDelivery(10*time.Millisecond))
+	m.infof("nobody listens")
+	if m.Dropped() != 1 {
+		t.Errorf("Expected message dropped after the timeout, got %d drops", m.Dropped())
+	}
+}
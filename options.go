@@ -49,9 +49,7 @@ func WithUMask(umask string) ServiceOpt {
 // WithLimitNOFILE sets the maximum number of open file descriptors for the service.
 // The limit parameter can be a number or "infinity".
 func WithLimitNOFILE(limit string) ServiceOpt {
-	return func(c *ServiceConfig) {
-		c.ServiceLines = append(c.ServiceLines, fmt.Sprintf("LimitNOFILE=%s", limit))
-	}
+	return WithLimit(LimitNOFILE, limit)
 }
 
 // WithExecReload configures service reload and timeout behavior.
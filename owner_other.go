@@ -0,0 +1,10 @@
+//go:build !unix
+
+package systemd
+
+import "os"
+
+// fileOwner is not supported on platforms without Unix file ownership.
+func fileOwner(os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
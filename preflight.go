@@ -0,0 +1,301 @@
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// systemdRunDir exists when PID 1 is systemd (see sd_booted(3)).
+const systemdRunDir = "/run/systemd/system"
+
+// systemdVersionRe matches the first line of systemctl --version.
+var systemdVersionRe = regexp.MustCompile(`^systemd (\d+)`)
+
+// directiveVersions lists the systemd release introducing each directive the
+// package can generate. Directives missing here predate every supported release.
+var directiveVersions = map[string]int{
+	"RuntimeDirectory":           211,
+	"RuntimeDirectoryMode":       211,
+	"CPUQuota":                   213,
+	"TasksMax":                   227,
+	"IOWeight":                   230,
+	"MemoryHigh":                 231,
+	"MemoryMax":                  231,
+	"CPUWeight":                  232,
+	"DynamicUser":                232,
+	"MemorySwapMax":              232,
+	"StateDirectory":             235,
+	"StateDirectoryMode":         235,
+	"CacheDirectory":             235,
+	"CacheDirectoryMode":         235,
+	"LogsDirectory":              235,
+	"LogsDirectoryMode":          235,
+	"ConfigurationDirectory":     235,
+	"ConfigurationDirectoryMode": 235,
+	"LogLevelMax":                236,
+	"LogExtraFields":             236,
+	"LogRateLimitIntervalSec":    240,
+	"LogRateLimitBurst":          240,
+	"LogNamespace":               245,
+}
+
+// PreflightCheck is the outcome of a single Preflight check.
+type PreflightCheck struct {
+	Name string // What was checked, e.g. "command rsyslogd" or "directive LogNamespace"
+	Err  error  // Why the check failed, nil if it passed
+}
+
+// PreflightReport lists the outcome of every check run by Preflight.
+type PreflightReport struct {
+	Checks         []PreflightCheck
+	SystemdVersion int // Version reported by systemctl --version, 0 if unknown
+}
+
+// Failed returns the checks that did not pass.
+func (r *PreflightReport) Failed() []PreflightCheck {
+	var failed []PreflightCheck
+	for _, check := range r.Checks {
+		if check.Err != nil {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+// Err joins the errors of the failed checks, or returns nil if all passed.
+func (r *PreflightReport) Err() error {
+	var errs []error
+	for _, check := range r.Failed() {
+		errs = append(errs, fmt.Errorf("%s: %w", check.Name, check.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// add records the outcome of a check.
+func (r *PreflightReport) add(name string, err error) {
+	r.Checks = append(r.Checks, PreflightCheck{Name: name, Err: err})
+}
+
+// Preflight checks that the environment can run Install with the configuration,
+// without changing the system. It runs every check and reports all of them:
+//   - root privileges (skipped in user scope)
+//   - PID 1 is systemd
+//   - the commands Install runs are installed: systemctl, and depending on the
+//     configuration useradd, groupadd, usermod, systemd-sysusers,
+//     systemd-tmpfiles, setfacl, rsyslogd, logrotate and loginctl
+//   - BinaryPath is an executable file
+//   - the directories Install writes to are writable
+//   - the systemd version supports every directive of the service and slice units
+//
+// The returned error joins the failed checks and is nil if all of them passed.
+// Failures match ErrNotRoot, ErrSystemdUnavailable or exec.ErrNotFound where
+// they apply.
+func (m *Manager) Preflight() (*PreflightReport, error) {
+	c := m.cfg
+	r := &PreflightReport{}
+
+	if !c.userScope {
+		r.add("root", checkRoot())
+	}
+	r.add("systemd", checkSystemdBooted())
+
+	for _, cmd := range requiredCommands(c, m.linger) {
+		r.add("command "+cmd, checkCommand(cmd))
+	}
+
+	r.add("binary "+c.BinaryPath, checkExecutable(c.BinaryPath))
+
+	for _, d := range targetDirs(c) {
+		r.add("directory "+d.path, checkWritableDir(d.path, d.create))
+	}
+
+	version, err := systemdVersion()
+	r.SystemdVersion = version
+	r.add("systemd version", err)
+	for _, directive := range unitDirectives(c) {
+		if required, ok := directiveVersions[directive]; ok && version > 0 {
+			var err error
+			if version < required {
+				err = fmt.Errorf("requires systemd %d or later, found %d", required, version)
+			}
+			r.add("directive "+directive, err)
+		}
+	}
+
+	return r, r.Err()
+}
+
+// checkSystemdBooted returns ErrSystemdUnavailable unless PID 1 is systemd.
+func checkSystemdBooted() error {
+	info, err := os.Stat(systemdRunDir)
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("%w: %s does not exist", ErrSystemdUnavailable, systemdRunDir)
+	}
+	return nil
+}
+
+// requiredCommands returns the commands Install runs for the configuration.
+func requiredCommands(c *ServiceConfig, linger bool) []string {
+	cmds := []string{"systemctl"}
+	if c.userScope {
+		if linger {
+			cmds = append(cmds, "loginctl")
+		}
+		return cmds
+	}
+
+	switch {
+	case c.DynamicUser:
+	case c.UseSysusers:
+		cmds = append(cmds, "systemd-sysusers")
+	default:
+		cmds = append(cmds, "groupadd", "useradd")
+		if c.Account != nil && len(c.Account.Groups) > 0 {
+			cmds = append(cmds, "usermod")
+		}
+	}
+	if usesTmpfiles(c) {
+		cmds = append(cmds, "systemd-tmpfiles")
+	}
+	if c.LogDir != "" {
+		if len(logDirConfig(c).ReadGroups) > 0 {
+			cmds = append(cmds, "setfacl")
+		}
+		cmds = append(cmds, "rsyslogd")
+		if c.MakeLogrotate {
+			cmds = append(cmds, "logrotate")
+		}
+	}
+	return cmds
+}
+
+// checkCommand reports whether cmd is found in PATH. Missing systemd tools also
+// match ErrSystemdUnavailable.
+func checkCommand(cmd string) error {
+	if _, err := exec.LookPath(cmd); err != nil {
+		if systemdTools[cmd] {
+			return fmt.Errorf("%w: %w", ErrSystemdUnavailable, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// checkExecutable reports whether path is an executable regular file.
+func checkExecutable(path string) error {
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		return err
+	case !info.Mode().IsRegular():
+		return fmt.Errorf("%s is not a regular file", path)
+	case info.Mode().Perm()&0o111 == 0:
+		return fmt.Errorf("%s is not executable", path)
+	}
+	return nil
+}
+
+// targetDir is a directory Install writes to. Directories with create set are
+// created by Install when missing.
+type targetDir struct {
+	path   string
+	create bool
+}
+
+// targetDirs returns the directories Install writes to for the configuration.
+func targetDirs(c *ServiceConfig) []targetDir {
+	dirs := []targetDir{{filepath.Dir(c.SystemdFile), c.userScope}}
+	if c.userScope {
+		return dirs
+	}
+
+	if c.UseSysusers && !c.DynamicUser {
+		dirs = append(dirs, targetDir{filepath.Dir(sysusersPath(c)), false})
+	}
+	if usesTmpfiles(c) {
+		dirs = append(dirs, targetDir{filepath.Dir(tmpfilesPath(c)), false})
+	}
+	if c.LogDir != "" {
+		dirs = append(dirs,
+			targetDir{c.LogDir, true},
+			targetDir{filepath.Dir(rsyslogPath(c)), false})
+		if c.MakeLogrotate {
+			dirs = append(dirs, targetDir{filepath.Dir(logrotateCorePath(c)), false})
+		}
+	}
+	if c.LogNamespace != nil {
+		dirs = append(dirs, targetDir{journaldConfDir, false})
+	}
+	return dirs
+}
+
+// checkWritableDir reports whether files can be created in dir. If create is
+// set and dir does not exist, its closest existing parent must be writable.
+func checkWritableDir(dir string, create bool) error {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) && create {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		return checkWritableDir(parent, true)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	// Creating a file is the only portable check that honours ACLs and read-only mounts
+	f, err := os.CreateTemp(dir, ".preflight-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	_ = f.Close()
+	return os.Remove(f.Name())
+}
+
+// systemdVersion returns the version of systemd reported by systemctl.
+func systemdVersion() (int, error) {
+	out, _, err := runCommand("systemctl", "--version")
+	if err != nil {
+		return 0, err
+	}
+	match := systemdVersionRe.FindSubmatch(out)
+	if match == nil {
+		return 0, fmt.Errorf("unrecognized systemctl --version output %q", strings.SplitN(string(out), "\n", 2)[0])
+	}
+	return strconv.Atoi(string(match[1]))
+}
+
+// unitDirectives returns the sorted directive names of the service and slice units.
+func unitDirectives(c *ServiceConfig) []string {
+	lines := c.ServiceLines
+	if c.Slice != nil {
+		if sliceLines, err := c.Slice.directives(); err == nil {
+			lines = append(append([]string(nil), lines...), sliceLines...)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, line := range lines {
+		name, _, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
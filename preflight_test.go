@@ -0,0 +1,147 @@
+package systemd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// preflightCheck returns the check with the given name from the report
+func preflightCheck(t *testing.T, r *PreflightReport, name string) PreflightCheck {
+	t.Helper()
+	for _, check := range r.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	t.Fatalf("Expected check %q in report, got %+v", name, r.Checks)
+	return PreflightCheck{}
+}
+
+// TestPreflight tests that every check is reported rather than the first failure
+func TestPreflight(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "myapp")
+	if err := os.WriteFile(binary, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fakeCommands(t, map[string]string{
+		"systemctl": `echo "systemd 239 (239-78.el8)"; echo "+PAM +AUDIT"`,
+		"useradd":   "exit 0",
+		"groupadd":  "exit 0",
+	})
+
+	cfg := NewServiceConfig("app", "app", binary, filepath.Join(dir, "missing", "logs"),
+		WithServiceLine("DynamicUser=yes"), WithLogNamespace(JournalNamespace{Name: "app"}),
+		WithCPUWeight(100), WithLogRateLimit(0, 0))
+	cfg.SystemdFile = filepath.Join(dir, "app.service")
+	m := NewManager(&cfg)
+
+	r, err := m.Preflight()
+	if err == nil {
+		t.Fatal("Expected preflight to fail")
+	}
+	if r.SystemdVersion != 239 {
+		t.Errorf("Expected systemd version 239, got %d", r.SystemdVersion)
+	}
+
+	if check := preflightCheck(t, r, "root"); (check.Err == nil) != (os.Geteuid() == 0) ||
+		(check.Err != nil && !errors.Is(check.Err, ErrNotRoot)) {
+		t.Errorf("Unexpected root check result: %v", check.Err)
+	}
+	_, statErr := os.Stat(systemdRunDir)
+	if check := preflightCheck(t, r, "systemd"); (check.Err == nil) != (statErr == nil) ||
+		(check.Err != nil && !errors.Is(check.Err, ErrSystemdUnavailable)) {
+		t.Errorf("Unexpected systemd check result: %v", check.Err)
+	}
+
+	for _, name := range []string{"command systemctl", "command useradd", "command groupadd",
+		"directory " + dir, "directory " + cfg.LogDir, "systemd version",
+		"directive DynamicUser", "directive CPUWeight"} {
+		if check := preflightCheck(t, r, name); check.Err != nil {
+			t.Errorf("Expected %s to pass, got %v", name, check.Err)
+		}
+	}
+
+	if check := preflightCheck(t, r, "command rsyslogd"); !errors.Is(check.Err, exec.ErrNotFound) {
+		t.Errorf("Expected missing rsyslogd, got %v", check.Err)
+	}
+	if check := preflightCheck(t, r, "binary "+binary); check.Err == nil || !strings.Contains(check.Err.Error(), "not executable") {
+		t.Errorf("Expected non-executable binary, got %v", check.Err)
+	}
+	for _, directive := range []string{"LogNamespace", "LogRateLimitIntervalSec", "LogRateLimitBurst"} {
+		check := preflightCheck(t, r, "directive "+directive)
+		if check.Err == nil || !strings.Contains(check.Err.Error(), "found 239") {
+			t.Errorf("Expected %s to need a newer systemd, got %v", directive, check.Err)
+		}
+	}
+	if !strings.Contains(err.Error(), "directive LogNamespace: requires systemd 245 or later") {
+		t.Errorf("Expected joined error to name failed checks, got %v", err)
+	}
+	if len(r.Failed()) == 0 || len(r.Failed()) == len(r.Checks) {
+		t.Errorf("Expected some failed checks, got %d of %d", len(r.Failed()), len(r.Checks))
+	}
+}
+
+// TestPreflightUserScope tests the checks run for user scope services
+func TestPreflightUserScope(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("PATH", t.TempDir())
+
+	binary := filepath.Join(configHome, "myapp")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := NewServiceConfig("nobody", "nogroup", binary, "")
+	r, _ := NewManager(&cfg, WithUserScope(), WithLinger()).Preflight()
+
+	var names []string
+	for _, check := range r.Checks {
+		names = append(names, check.Name)
+	}
+	want := []string{"systemd", "command systemctl", "command loginctl", "binary " + binary,
+		"directory " + filepath.Join(configHome, "systemd", "user"), "systemd version"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected checks %v, got %v", want, names)
+	}
+
+	if check := preflightCheck(t, r, "command systemctl"); !errors.Is(check.Err, ErrSystemdUnavailable) {
+		t.Errorf("Expected missing systemctl to match ErrSystemdUnavailable, got %v", check.Err)
+	}
+	if check := preflightCheck(t, r, "binary "+binary); check.Err != nil {
+		t.Errorf("Expected executable binary, got %v", check.Err)
+	}
+	if check := preflightCheck(t, r, "directory "+filepath.Join(configHome, "systemd", "user")); check.Err != nil {
+		t.Errorf("Expected creatable user unit directory, got %v", check.Err)
+	}
+}
+
+// TestUnitDirectives tests the directive names collected from the units
+func TestUnitDirectives(t *testing.T) {
+	cfg := NewServiceConfig("app", "app", "/usr/bin/app", "",
+		WithWatchdog("30s"), WithMemoryMax("1G"), WithWatchdog("10s"),
+		WithSliceUnit(SliceConfig{Name: "apps", TasksMax: "100"}))
+
+	got := strings.Join(unitDirectives(&cfg), ",")
+	if got != "MemoryMax,Slice,TasksMax,WatchdogSec" {
+		t.Errorf("Expected sorted unique directives, got %s", got)
+	}
+}
+
+// TestPreflightRuntimeDirectory tests that RuntimeDirectoryMode is accepted on older releases
+func TestPreflightRuntimeDirectory(t *testing.T) {
+	fakeCommands(t, map[string]string{"systemctl": `echo "systemd 219"`})
+
+	cfg := NewServiceConfig("app", "app", "/usr/bin/app", "",
+		WithServiceLine("RuntimeDirectory=app"), WithServiceLine("RuntimeDirectoryMode=0750"))
+	r, _ := NewManager(&cfg).Preflight()
+	for _, name := range []string{"directive RuntimeDirectory", "directive RuntimeDirectoryMode"} {
+		if check := preflightCheck(t, r, name); check.Err != nil {
+			t.Errorf("Expected %s to pass on systemd 219, got %v", name, check.Err)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package systemd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Limit identifies a systemd Limit* directive that maps to a setrlimit(2) resource.
+type Limit string
+
+// Process resource limits supported by systemd.exec(5).
+const (
+	LimitCPU        Limit = "LimitCPU"        // CPU time (time span, seconds by default)
+	LimitFSIZE      Limit = "LimitFSIZE"      // Maximum file size (bytes)
+	LimitDATA       Limit = "LimitDATA"       // Data segment size (bytes)
+	LimitSTACK      Limit = "LimitSTACK"      // Stack size (bytes)
+	LimitCORE       Limit = "LimitCORE"       // Core dump size (bytes)
+	LimitRSS        Limit = "LimitRSS"        // Resident set size (bytes)
+	LimitNOFILE     Limit = "LimitNOFILE"     // Open file descriptors (count)
+	LimitAS         Limit = "LimitAS"         // Address space size (bytes)
+	LimitNPROC      Limit = "LimitNPROC"      // Number of processes (count)
+	LimitMEMLOCK    Limit = "LimitMEMLOCK"    // Locked memory (bytes)
+	LimitLOCKS      Limit = "LimitLOCKS"      // File locks (count)
+	LimitSIGPENDING Limit = "LimitSIGPENDING" // Queued signals (count)
+	LimitMSGQUEUE   Limit = "LimitMSGQUEUE"   // POSIX message queue size (bytes)
+	LimitNICE       Limit = "LimitNICE"       // Nice ceiling (count or +/-nice value)
+	LimitRTPRIO     Limit = "LimitRTPRIO"     // Real-time priority (count)
+	LimitRTTIME     Limit = "LimitRTTIME"     // Real-time CPU time (time span, microseconds by default)
+)
+
+// limitKind describes the value syntax accepted by a Limit directive.
+type limitKind int
+
+const (
+	limitCount limitKind = iota
+	limitBytes
+	limitTime
+)
+
+// limitKinds maps each supported Limit directive to its value syntax.
+var limitKinds = map[Limit]limitKind{
+	LimitCPU:        limitTime,
+	LimitFSIZE:      limitBytes,
+	LimitDATA:       limitBytes,
+	LimitSTACK:      limitBytes,
+	LimitCORE:       limitBytes,
+	LimitRSS:        limitBytes,
+	LimitNOFILE:     limitCount,
+	LimitAS:         limitBytes,
+	LimitNPROC:      limitCount,
+	LimitMEMLOCK:    limitBytes,
+	LimitLOCKS:      limitCount,
+	LimitSIGPENDING: limitCount,
+	LimitMSGQUEUE:   limitBytes,
+	LimitNICE:       limitCount,
+	LimitRTPRIO:     limitCount,
+	LimitRTTIME:     limitTime,
+}
+
+var (
+	sizeRe     = regexp.MustCompile(`^(\d+[KMGTPE]?|\d+\.\d+[KMGTPE])$`)
+	percentRe  = regexp.MustCompile(`^\d+(\.\d+)?%$`)
+	countRe    = regexp.MustCompile(`^\d+$`)
+	niceRe     = regexp.MustCompile(`^[+-]\d+$`)
+	timeSpanRe = regexp.MustCompile(`^(\d+(\.\d+)?\s*(us|usec|ms|msec|s|sec|m|min|h|hr|d|w|M|y)?\s*)+$`)
+	sliceRe    = regexp.MustCompile(`^[a-zA-Z0-9_.\\:-]+\.slice$`)
+)
+
+// checkPercent checks that a percentage matching percentRe is at most 100%.
+func checkPercent(v string) error {
+	p, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+	if err != nil || p > 100 {
+		return fmt.Errorf("percentage %q exceeds 100%%", v)
+	}
+	return nil
+}
+
+// ParseSize validates a systemd resource size and returns its normalized form.
+// Accepted values are "infinity", a byte count with an optional base-1024 suffix
+// (K, M, G, T, P, E), and, when allowPercent is true, a percentage such as "50%".
+func ParseSize(s string, allowPercent bool) (string, error) {
+	v := strings.TrimSpace(s)
+	switch {
+	case v == "":
+		return "", fmt.Errorf("empty size")
+	case v == "infinity":
+		return v, nil
+	case percentRe.MatchString(v):
+		if !allowPercent {
+			return "", fmt.Errorf("percentage %q not allowed here", v)
+		}
+		if err := checkPercent(v); err != nil {
+			return "", err
+		}
+		return v, nil
+	}
+
+	upper := strings.ToUpper(v)
+	if !sizeRe.MatchString(upper) {
+		return "", fmt.Errorf("invalid size %q", s)
+	}
+	return upper, nil
+}
+
+// WithMemoryMax sets the hard memory limit (MemoryMax=) for the service cgroup.
+// The value may be a byte size ("512M"), a percentage of physical memory ("25%") or "infinity".
+func WithMemoryMax(size string) ServiceOpt {
+	return sizeDirective("MemoryMax", size, true)
+}
+
+// WithMemoryHigh sets the memory throttling threshold (MemoryHigh=) for the service cgroup.
+// The value may be a byte size, a percentage of physical memory or "infinity".
+func WithMemoryHigh(size string) ServiceOpt {
+	return sizeDirective("MemoryHigh", size, true)
+}
+
+// WithMemorySwapMax sets the swap usage limit (MemorySwapMax=) for the service cgroup.
+// The value may be a byte size, a percentage of swap space or "infinity".
+func WithMemorySwapMax(size string) ServiceOpt {
+	return sizeDirective("MemorySwapMax", size, true)
+}
+
+// WithCPUQuota limits the CPU time of the service (CPUQuota=) as a percentage of one CPU.
+// Values above 100 allow the service to use more than one CPU.
+func WithCPUQuota(percent int) ServiceOpt {
+	return func(c *ServiceConfig) {
+		if percent <= 0 {
+			c.invalid("CPUQuota", fmt.Errorf("quota must be positive, got %d", percent))
+			return
+		}
+		c.ServiceLines = append(c.ServiceLines, fmt.Sprintf("CPUQuota=%d%%", percent))
+	}
+}
+
+// WithCPUWeight sets the relative CPU weight (CPUWeight=) of the service, in the range 1-10000.
+func WithCPUWeight(weight int) ServiceOpt {
+	return rangeDirective("CPUWeight", weight, 1, 10000)
+}
+
+// WithIOWeight sets the relative block IO weight (IOWeight=) of the service, in the range 1-10000.
+func WithIOWeight(weight int) ServiceOpt {
+	return rangeDirective("IOWeight", weight, 1, 10000)
+}
+
+// WithTasksMax limits the number of tasks (TasksMax=) the service may create.
+// The value may be a count, a percentage of the system limit or "infinity".
+func WithTasksMax(limit string) ServiceOpt {
+	return func(c *ServiceConfig) {
+		v := strings.TrimSpace(limit)
+		if v != "infinity" && !countRe.MatchString(v) && !percentRe.MatchString(v) {
+			c.invalid("TasksMax", fmt.Errorf("invalid task limit %q", limit))
+			return
+		}
+		if percentRe.MatchString(v) {
+			if err := checkPercent(v); err != nil {
+				c.invalid("TasksMax", err)
+				return
+			}
+		}
+		c.ServiceLines = append(c.ServiceLines, "TasksMax="+v)
+	}
+}
+
+// WithSlice places the service into the named slice unit (Slice=).
+// The name must end in ".slice", e.g. "ourteam.slice".
+func WithSlice(name string) ServiceOpt {
+	return func(c *ServiceConfig) {
+		if !sliceRe.MatchString(name) {
+			c.invalid("Slice", fmt.Errorf("invalid slice name %q", name))
+			return
+		}
+		c.ServiceLines = append(c.ServiceLines, "Slice="+name)
+	}
+}
+
+// WithOOMScoreAdjust sets the OOM killer adjustment (OOMScoreAdjust=), in the range -1000 to 1000.
+func WithOOMScoreAdjust(score int) ServiceOpt {
+	return rangeDirective("OOMScoreAdjust", score, -1000, 1000)
+}
+
+// WithNice sets the scheduling priority (Nice=) of the service, in the range -20 to 19.
+func WithNice(nice int) ServiceOpt {
+	return rangeDirective("Nice", nice, -20, 19)
+}
+
+// WithCPUAffinity pins the service to the given CPU indices (CPUAffinity=).
+func WithCPUAffinity(cpus ...int) ServiceOpt {
+	return func(c *ServiceConfig) {
+		if len(cpus) == 0 {
+			c.invalid("CPUAffinity", fmt.Errorf("no CPUs given"))
+			return
+		}
+		list := make([]string, 0, len(cpus))
+		for _, cpu := range cpus {
+			if cpu < 0 {
+				c.invalid("CPUAffinity", fmt.Errorf("invalid CPU index %d", cpu))
+				return
+			}
+			list = append(list, strconv.Itoa(cpu))
+		}
+		c.ServiceLines = append(c.ServiceLines, "CPUAffinity="+strings.Join(list, " "))
+	}
+}
+
+// WithLimit sets a process resource limit from the Limit* family.
+// The value may be a single limit or a "soft:hard" pair; each side accepts "infinity"
+// or a value in the syntax of the limit (byte size, count or time span).
+func WithLimit(limit Limit, value string) ServiceOpt {
+	return func(c *ServiceConfig) {
+		v, err := parseLimit(limit, value)
+		if err != nil {
+			c.invalid(string(limit), err)
+			return
+		}
+		c.ServiceLines = append(c.ServiceLines, fmt.Sprintf("%s=%s", limit, v))
+	}
+}
+
+// parseLimit validates a Limit* value, including "soft:hard" pairs.
+func parseLimit(limit Limit, value string) (string, error) {
+	kind, ok := limitKinds[limit]
+	if !ok {
+		return "", fmt.Errorf("unknown resource limit %q", limit)
+	}
+
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) > 2 {
+		return "", fmt.Errorf("invalid limit %q", value)
+	}
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		switch {
+		case p == "infinity":
+		case kind == limitBytes:
+			size, err := ParseSize(p, false)
+			if err != nil {
+				return "", err
+			}
+			p = size
+		case kind == limitTime:
+			if !timeSpanRe.MatchString(p) {
+				return "", fmt.Errorf("invalid time span %q", p)
+			}
+		case limit == LimitNICE && niceRe.MatchString(p):
+		case !countRe.MatchString(p):
+			return "", fmt.Errorf("invalid count %q", p)
+		}
+		parts[i] = p
+	}
+	return strings.Join(parts, ":"), nil
+}
+
+// sizeDirective returns a ServiceOpt that validates and appends a size-valued directive.
+func sizeDirective(directive, size string, allowPercent bool) ServiceOpt {
+	return func(c *ServiceConfig) {
+		v, err := ParseSize(size, allowPercent)
+		if err != nil {
+			c.invalid(directive, err)
+			return
+		}
+		c.ServiceLines = append(c.ServiceLines, fmt.Sprintf("%s=%s", directive, v))
+	}
+}
+
+// rangeDirective returns a ServiceOpt that validates and appends an integer directive within [lo, hi].
+func rangeDirective(directive string, v, lo, hi int) ServiceOpt {
+	return func(c *ServiceConfig) {
+		if v < lo || v > hi {
+			c.invalid(directive, fmt.Errorf("value %d out of range %d..%d", v, lo, hi))
+			return
+		}
+		c.ServiceLines = append(c.ServiceLines, fmt.Sprintf("%s=%d", directive, v))
+	}
+}
@@ -0,0 +1,133 @@
+package systemd
+
+import (
+	"testing"
+)
+
+// TestParseSize tests size parsing and normalization
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input        string
+		allowPercent bool
+		expected     string
+		wantErr      bool
+	}{
+		{"512M", false, "512M", false},
+		{"512m", false, "512M", false},
+		{"1.5G", false, "1.5G", false},
+		{"1048576", false, "1048576", false},
+		{"infinity", false, "infinity", false},
+		{"50%", true, "50%", false},
+		{"50%", false, "", true},
+		{"150%", true, "", true},
+		{"1.5", false, "", true},
+		{"12Q", false, "", true},
+		{"", false, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseSize(tt.input, tt.allowPercent)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseSize(%q) = %q, expected %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestResourceOptions tests the typed resource control options
+func TestResourceOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opt      ServiceOpt
+		expected string
+	}{
+		{"MemoryMax", WithMemoryMax("2G"), "MemoryMax=2G"},
+		{"MemoryHigh", WithMemoryHigh("75%"), "MemoryHigh=75%"},
+		{"MemorySwapMax", WithMemorySwapMax("0"), "MemorySwapMax=0"},
+		{"CPUQuota", WithCPUQuota(150), "CPUQuota=150%"},
+		{"CPUWeight", WithCPUWeight(200), "CPUWeight=200"},
+		{"IOWeight", WithIOWeight(50), "IOWeight=50"},
+		{"TasksMax", WithTasksMax("4096"), "TasksMax=4096"},
+		{"Slice", WithSlice("ourteam.slice"), "Slice=ourteam.slice"},
+		{"OOMScoreAdjust", WithOOMScoreAdjust(-500), "OOMScoreAdjust=-500"},
+		{"Nice", WithNice(5), "Nice=5"},
+		{"CPUAffinity", WithCPUAffinity(0, 2, 3), "CPUAffinity=0 2 3"},
+		{"LimitMEMLOCK", WithLimit(LimitMEMLOCK, "infinity"), "LimitMEMLOCK=infinity"},
+		{"LimitCORE", WithLimit(LimitCORE, "0:1g"), "LimitCORE=0:1G"},
+		{"LimitCPU", WithLimit(LimitCPU, "1h 30min"), "LimitCPU=1h 30min"},
+		{"LimitNICE", WithLimit(LimitNICE, "-5"), "LimitNICE=-5"},
+		{"LimitNOFILE", WithLimitNOFILE("100000"), "LimitNOFILE=100000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ServiceConfig{}
+			tt.opt(&cfg)
+
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			if len(cfg.ServiceLines) != 1 || cfg.ServiceLines[0] != tt.expected {
+				t.Errorf("Expected ServiceLines to contain '%s', got %v", tt.expected, cfg.ServiceLines)
+			}
+		})
+	}
+}
+
+// TestResourceOptionsValidation tests that invalid resource values are reported by Validate
+func TestResourceOptionsValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  ServiceOpt
+	}{
+		{"MemoryMax", WithMemoryMax("lots")},
+		{"CPUQuota", WithCPUQuota(0)},
+		{"CPUWeight", WithCPUWeight(10001)},
+		{"IOWeight", WithIOWeight(0)},
+		{"TasksMax", WithTasksMax("many")},
+		{"TasksMax percentage", WithTasksMax("150%")},
+		{"Slice", WithSlice("ourteam")},
+		{"OOMScoreAdjust", WithOOMScoreAdjust(1001)},
+		{"Nice", WithNice(-21)},
+		{"CPUAffinity", WithCPUAffinity()},
+		{"LimitNOFILE", WithLimitNOFILE("1M")},
+		{"LimitSTACK", WithLimit(LimitSTACK, "8M:4M:2M")},
+		{"Unknown", WithLimit(Limit("LimitBOGUS"), "1")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ServiceConfig{}
+			tt.opt(&cfg)
+
+			if err := cfg.Validate(); err == nil {
+				t.Error("Expected validation error, got nil")
+			}
+			if len(cfg.ServiceLines) != 0 {
+				t.Errorf("Expected no ServiceLines for invalid value, got %v", cfg.ServiceLines)
+			}
+		})
+	}
+}
+
+// TestInstallRejectsInvalidConfig tests that Install fails before touching the system
+func TestInstallRejectsInvalidConfig(t *testing.T) {
+	cfg := NewServiceConfig("testuser", "testgroup", "/usr/bin/test", "",
+		WithCPUWeight(0))
+
+	errChan := make(chan error, 1)
+	m := NewManager(&cfg, WithErrorChan(errChan))
+
+	if err := m.Install(); err == nil {
+		t.Fatal("Expected Install to fail on invalid configuration")
+	}
+	select {
+	case <-errChan:
+	default:
+		t.Error("Expected error to be reported on the error channel")
+	}
+}
@@ -0,0 +1,301 @@
+package systemd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// StreamFilter selects which rsyslog message property identifies the service's messages.
+type StreamFilter int
+
+const (
+	// FilterProgramName matches $programname against the service's ProgramName.
+	FilterProgramName StreamFilter = iota
+	// FilterSyslogTag matches $syslogtag against "<ProgramName>:" or "<ProgramName>[pid]:".
+	FilterSyslogTag
+	// FilterSystemdUnit matches the journal field $!_SYSTEMD_UNIT against ServiceName.
+	// It requires rsyslog to read the journal through imjournal.
+	FilterSystemdUnit
+)
+
+// StreamMatch selects how the stream=<name> field is matched within a message.
+type StreamMatch int
+
+const (
+	// MatchExact requires stream=<name> as a whole whitespace-delimited token anywhere
+	// in the message, so "HTTP" does not capture "stream=HTTP-ACCESS".
+	MatchExact StreamMatch = iota
+	// MatchPrefix requires the message to start with the stream=<name> token.
+	MatchPrefix
+	// MatchRegex treats the stream name as a POSIX extended regular expression
+	// matched against the whole value of the stream field.
+	MatchRegex
+)
+
+// streamNameRe restricts stream names used with literal match modes.
+var streamNameRe = regexp.MustCompile(`^[^\s'"\\]+$`)
+
+// WithProgramName sets the syslog identifier used to select the service's messages
+// in rsyslog. It defaults to the base name of the binary.
+func WithProgramName(name string) ServiceOpt {
+	return func(c *ServiceConfig) {
+		c.ProgramName = name
+	}
+}
+
+// WithStreamFilter selects the message property used to restrict stream routing
+// to the service's own messages.
+func WithStreamFilter(f StreamFilter) ServiceOpt {
+	return func(c *ServiceConfig) {
+		c.StreamFilter = f
+	}
+}
+
+// WithStreamMatch selects how stream=<name> fields are matched within messages.
+func WithStreamMatch(m StreamMatch) ServiceOpt {
+	return func(c *ServiceConfig) {
+		c.StreamMatch = m
+	}
+}
+
+// programName returns the syslog identifier of the service.
+func programName(c *ServiceConfig) string {
+	if c.ProgramName != "" {
+		return c.ProgramName
+	}
+	return filepath.Base(c.BinaryPath)
+}
+
+// validateStreams checks stream names against the configured match mode.
+func validateStreams(c *ServiceConfig) []error {
+	var errs []error
+	for _, name := range sortedStreams(c.Streams) {
+		if c.StreamMatch == MatchRegex {
+			if _, err := regexp.CompilePOSIX(name); err != nil {
+				errs = append(errs, fmt.Errorf("stream %q: %w", name, err))
+			}
+			continue
+		}
+		if !streamNameRe.MatchString(name) {
+			errs = append(errs, fmt.Errorf("stream %q: invalid stream name", name))
+		}
+	}
+	return errs
+}
+
+// sortedStreams returns the stream names in a stable order.
+func sortedStreams(streams map[string]string) []string {
+	names := make([]string, 0, len(streams))
+	for name := range streams {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// rsyslogSourceCondition returns the RainerScript expression selecting the service's messages.
+func rsyslogSourceCondition(c *ServiceConfig) string {
+	switch c.StreamFilter {
+	case FilterSyslogTag:
+		pattern := "^" + quoteERE(programName(c)) + `(\[[0-9]+\])?:$`
+		return fmt.Sprintf("re_match($syslogtag, %s)", rainerString(pattern))
+	case FilterSystemdUnit:
+		return fmt.Sprintf("$!_SYSTEMD_UNIT == %s", rainerString(c.ServiceName))
+	default:
+		return fmt.Sprintf("$programname == %s", rainerString(programName(c)))
+	}
+}
+
+// rsyslogStreamCondition returns the RainerScript expression matching a stream field.
+func rsyslogStreamCondition(c *ServiceConfig, stream string) string {
+	return fmt.Sprintf("re_match($msg, %s)", rainerString(streamPattern(c, stream)))
+}
+
+// streamPattern returns the POSIX extended regular expression matching a stream field.
+func streamPattern(c *ServiceConfig, stream string) string {
+	switch c.StreamMatch {
+	case MatchPrefix:
+		return `^[[:space:]]*stream=` + quoteERE(stream) + `([[:space:]]|$)`
+	case MatchRegex:
+		return `(^|[[:space:]])stream=(` + stream + `)([[:space:]]|$)`
+	default:
+		return `(^|[[:space:]])stream=` + quoteERE(stream) + `([[:space:]]|$)`
+	}
+}
+
+// quoteERE escapes POSIX extended regular expression metacharacters.
+func quoteERE(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`\.[]()*+?{}|^$`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// rainerString renders s as a single-quoted RainerScript string literal.
+func rainerString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+// renderRsyslogConf generates the rsyslog configuration routing each stream of
+// the service to its log file. Only messages from the service itself (see
+// StreamFilter) carrying a matching stream=<name> field (see StreamMatch) are
+// routed; routed messages are not processed by later rules. Streams with
+// forward targets are additionally sent to the remote collectors.
+func renderRsyslogConf(c *ServiceConfig) string {
+	owner, group := logOwner(c)
+	dir := logDirConfig(c)
+	source := rsyslogSourceCondition(c)
+
+	var templates, configs []string
+	for _, streamName := range sortedStreams(c.Streams) {
+		template, definition := streamTemplate(c, streamName)
+		if definition != "" && !slices.Contains(templates, definition) {
+			templates = append(templates, definition)
+		}
+
+		var forwards strings.Builder
+		for _, t := range streamForwards(c, streamName) {
+			forwards.WriteString("\t" + renderForwardAction(&t) + "\n")
+		}
+
+		streamConfig := fmt.Sprintf(`if %s and %s then {
+	action(type="omfile" file="%s/%s" template="%s"
+	       dirCreateMode="%04o" dirOwner="%s" dirGroup="%s"
+	       fileCreateMode="0640" fileOwner="%s" fileGroup="%s")
+%s	stop
+}`, source, rsyslogStreamCondition(c, streamName), c.LogDir, c.Streams[streamName],
+			template, dir.Mode, dir.Owner, dir.Group, owner, group, forwards.String())
+		configs = append(configs, streamConfig)
+	}
+
+	// Core modules (imuxsock, imklog, builtin omfile) are loaded by the main
+	// rsyslog.conf; loading them again here breaks hosts with several services.
+	return strings.Join(templates, "\n") + "\n" + strings.Join(configs, "\n") + "\n"
+}
+
+// writeRsyslogConf creates an rsyslog configuration file for log stream routing.
+// This configuration enables structured logging by routing the service's messages
+// containing 'stream=<name>' to specific log files with proper ownership and permissions.
+// The file is validated before and after installation and rsyslog is restarted so
+// the rules take effect; see installRsyslogConf.
+func writeRsyslogConf(c *ServiceConfig) error {
+	if len(c.Streams) == 0 {
+		return nil // No streams configured
+	}
+	content := renderRsyslogConf(c)
+	module, err := ensureOmrelp(c, rsyslogMainConf, rsyslogConfDir, content)
+	if err != nil {
+		return err
+	}
+	if err := installRsyslogConf(c, rsyslogPath(c), content); err != nil {
+		if module != "" {
+			_ = c.removeFile(module)
+		}
+		return err
+	}
+	return nil
+}
+
+// installRsyslogConf installs an rsyslog configuration fragment safely:
+//  1. The fragment is checked on its own with rsyslogd -N1 against a temporary config
+//  2. The fragment is written, keeping any previous version
+//  3. The complete system configuration is checked with rsyslogd -N1
+//  4. rsyslog is restarted to load the new rules
+//
+// If the system check or the restart fails, the previous file is restored (or the
+// new one removed) and rsyslog is restarted on the old configuration.
+func installRsyslogConf(c *ServiceConfig, path, content string) error {
+	if err := validateRsyslogFragment(c, content); err != nil {
+		return err
+	}
+
+	previous, err := os.ReadFile(path) // #nosec G304
+	hadPrevious := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	rollback := func() {
+		if hadPrevious {
+			_ = c.writeFile(path, previous)
+		} else {
+			_ = c.removeFile(path)
+		}
+	}
+
+	if err := c.writeFile(path, []byte(content)); err != nil {
+		return err
+	}
+
+	if err := c.execCommand("rsyslogd", "-N1"); err != nil {
+		rollback()
+		return fmt.Errorf("rsyslog configuration check failed, changes rolled back: %w", err)
+	}
+
+	if err := restartRsyslog(c); err != nil {
+		rollback()
+		_ = restartRsyslog(c)
+		return fmt.Errorf("rsyslog restart failed, changes rolled back: %w", err)
+	}
+
+	return nil
+}
+
+// validateRsyslogFragment checks a configuration fragment in isolation by
+// including it from a temporary main configuration.
+func validateRsyslogFragment(c *ServiceConfig, content string) error {
+	dir, err := os.MkdirTemp("", "rsyslog-check-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	fragment := filepath.Join(dir, "fragment.conf")
+	if err := os.WriteFile(fragment, []byte(content), 0o600); err != nil {
+		return err
+	}
+
+	mainConf := filepath.Join(dir, "rsyslog.conf")
+	if err := os.WriteFile(mainConf, []byte(rsyslogValidationConf(dir, fragment, strings.Contains(content, `type="omrelp"`))), 0o600); err != nil {
+		return err
+	}
+
+	if err := c.execCommand("rsyslogd", "-N1", "-f", mainConf); err != nil {
+		return fmt.Errorf("invalid rsyslog configuration: %w", err)
+	}
+	return nil
+}
+
+// rsyslogValidationConf returns a minimal main configuration that loads the
+// modules expected by generated fragments and includes the fragment.
+// omrelp is loaded when the fragment forwards over RELP; on the host it is
+// loaded by the shared fragment written by ensureOmrelp.
+func rsyslogValidationConf(workDir, fragment string, relp bool) string {
+	modules := `module(load="imuxsock")` + "\n"
+	if relp {
+		modules += `module(load="omrelp")` + "\n"
+	}
+	return fmt.Sprintf(`global(workDirectory="%s")
+%sinclude(file="%s")
+`, workDir, modules, fragment)
+}
+
+// restartRsyslog restarts rsyslog so it loads the current configuration.
+func restartRsyslog(c *ServiceConfig) error {
+	return c.execCommand("systemctl", "restart", "rsyslog.service")
+}
+
+// rsyslogPath returns the file path for the rsyslog configuration.
+func rsyslogPath(c *ServiceConfig) string {
+	return filepath.Join(rsyslogConfDir, c.UniqueName+".conf")
+}
@@ -0,0 +1,197 @@
+package systemd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestRenderRsyslogConf tests rsyslog configuration generation
+func TestRenderRsyslogConf(t *testing.T) {
+	cfg := ServiceConfig{
+		User:        "testuser",
+		Group:       "testgroup",
+		UniqueName:  "test-service",
+		ServiceName: "test-service.service",
+		BinaryPath:  "/usr/bin/myapp",
+		LogDir:      "/var/log/test",
+		Streams: map[string]string{
+			"HTTP":        "http.log",
+			"HTTP-ACCESS": "access.log",
+		},
+	}
+
+	expected := `template(name="test-service" type="string" string="%msg%\n")
+if $programname == 'myapp' and re_match($msg, '(^|[[:space:]])stream=HTTP([[:space:]]|$)') then {
+	action(type="omfile" file="/var/log/test/http.log" template="test-service"
+	       dirCreateMode="0750" dirOwner="testuser" dirGroup="testgroup"
+	       fileCreateMode="0640" fileOwner="testuser" fileGroup="testgroup")
+	stop
+}
+if $programname == 'myapp' and re_match($msg, '(^|[[:space:]])stream=HTTP-ACCESS([[:space:]]|$)') then {
+	action(type="omfile" file="/var/log/test/access.log" template="test-service"
+	       dirCreateMode="0750" dirOwner="testuser" dirGroup="testgroup"
+	       fileCreateMode="0640" fileOwner="testuser" fileGroup="testgroup")
+	stop
+}
+`
+	if got := renderRsyslogConf(&cfg); got != expected {
+		t.Errorf("Rsyslog config mismatch.\nExpected:\n%s\nGot:\n%s", expected, got)
+	}
+}
+
+// TestRsyslogSourceCondition tests the service selection filters
+func TestRsyslogSourceCondition(t *testing.T) {
+	cfg := ServiceConfig{ServiceName: "app.service", BinaryPath: "/opt/app/bin/app.d"}
+
+	tests := []struct {
+		filter   StreamFilter
+		expected string
+	}{
+		{FilterProgramName, `$programname == 'app.d'`},
+		{FilterSyslogTag, `re_match($syslogtag, '^app\\.d(\\[[0-9]+\\])?:$')`},
+		{FilterSystemdUnit, `$!_SYSTEMD_UNIT == 'app.service'`},
+	}
+
+	for _, tt := range tests {
+		cfg.StreamFilter = tt.filter
+		if got := rsyslogSourceCondition(&cfg); got != tt.expected {
+			t.Errorf("filter %d: expected %s, got %s", tt.filter, tt.expected, got)
+		}
+	}
+
+	WithProgramName("custom")(&cfg)
+	cfg.StreamFilter = FilterProgramName
+	if got := rsyslogSourceCondition(&cfg); got != `$programname == 'custom'` {
+		t.Errorf("Expected ProgramName override, got %s", got)
+	}
+}
+
+// TestStreamPattern tests that stream patterns match only the intended messages
+func TestStreamPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		match   StreamMatch
+		stream  string
+		message string
+		want    bool
+	}{
+		{"exact token", MatchExact, "HTTP", " level=INFO stream=HTTP msg=ok", true},
+		{"exact at end", MatchExact, "HTTP", "msg=ok stream=HTTP", true},
+		{"exact rejects longer name", MatchExact, "HTTP", " stream=HTTP-ACCESS msg=ok", false},
+		{"exact rejects other key", MatchExact, "HTTP", "upstream=HTTP", false},
+		{"exact escapes dots", MatchExact, "a.b", "stream=axb", false},
+		{"prefix at start", MatchPrefix, "CORE", " stream=CORE msg=ok", true},
+		{"prefix elsewhere", MatchPrefix, "CORE", "msg=ok stream=CORE", false},
+		{"regex alternation", MatchRegex, "HTTP|HTTP-ACCESS", "stream=HTTP-ACCESS", true},
+		{"regex anchored value", MatchRegex, "HTTP", "stream=HTTP-ERROR", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ServiceConfig{StreamMatch: tt.match}
+			re := regexp.MustCompilePOSIX(streamPattern(&cfg, tt.stream))
+			if got := re.MatchString(tt.message); got != tt.want {
+				t.Errorf("pattern %s on %q = %v, want %v", re, tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateStreams tests stream name validation per match mode
+func TestValidateStreams(t *testing.T) {
+	cfg := ServiceConfig{Streams: map[string]string{"bad name": "bad.log"}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "bad name") {
+		t.Errorf("Expected invalid stream name error, got %v", err)
+	}
+
+	cfg = ServiceConfig{StreamMatch: MatchRegex, Streams: map[string]string{"(unclosed": "x.log"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected invalid regex error")
+	}
+
+	cfg = ServiceConfig{StreamMatch: MatchRegex, Streams: map[string]string{"HTTP(-ACCESS)?": "x.log"}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Unexpected error for valid regex stream: %v", err)
+	}
+}
+
+// TestInstallRsyslogConf tests validation, activation and rollback of rsyslog fragments
+func TestInstallRsyslogConf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.conf")
+
+	t.Run("fragment invalid", func(t *testing.T) {
+		dir := fakeCommands(t, map[string]string{"rsyslogd": "exit 1", "systemctl": "exit 0"})
+		if err := installRsyslogConf(&ServiceConfig{}, path, "new"); err == nil {
+			t.Fatal("Expected validation error")
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Error("Expected no file to be written when the fragment is invalid")
+		}
+		if strings.Contains(fakeCalls(t, dir), "systemctl") {
+			t.Error("Expected rsyslog not to be restarted")
+		}
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		dir := fakeCommands(t, map[string]string{"rsyslogd": "exit 0", "systemctl": "exit 0"})
+		if err := installRsyslogConf(&ServiceConfig{}, path, "v1"); err != nil {
+			t.Fatalf("installRsyslogConf failed: %v", err)
+		}
+		if content, _ := os.ReadFile(path); string(content) != "v1" {
+			t.Errorf("Expected installed content v1, got %q", content)
+		}
+		calls := fakeCalls(t, dir)
+		if !strings.Contains(calls, "rsyslogd -N1 -f ") || !strings.Contains(calls, "rsyslogd -N1\n") {
+			t.Errorf("Expected fragment and system checks, got calls:\n%s", calls)
+		}
+		if !strings.HasSuffix(calls, "systemctl restart rsyslog.service\n") {
+			t.Errorf("Expected rsyslog restart after validation, got calls:\n%s", calls)
+		}
+	})
+
+	t.Run("system check fails", func(t *testing.T) {
+		// Only the system-wide check (without -f) fails
+		fakeCommands(t, map[string]string{
+			"rsyslogd":  `[ "$2" = "-f" ] || exit 1`,
+			"systemctl": "exit 0",
+		})
+		var files []string
+		c := &ServiceConfig{events: func(e Event) {
+			if e.Type == EventFileWritten || e.Type == EventFileRemoved {
+				files = append(files, fmt.Sprintf("%s %s", e.Type, filepath.Base(e.Path)))
+			}
+		}}
+		if err := installRsyslogConf(c, path, "v2"); err == nil {
+			t.Fatal("Expected system check error")
+		}
+		if content, _ := os.ReadFile(path); string(content) != "v1" {
+			t.Errorf("Expected previous content to be restored, got %q", content)
+		}
+
+		fresh := filepath.Join(filepath.Dir(path), "fresh.conf")
+		if err := installRsyslogConf(c, fresh, "v1"); err == nil {
+			t.Fatal("Expected system check error")
+		}
+		if _, err := os.Stat(fresh); !os.IsNotExist(err) {
+			t.Error("Expected new fragment to be removed")
+		}
+		expected := "file-written test.conf,file-written test.conf,file-written fresh.conf,file-removed fresh.conf"
+		if got := strings.Join(files, ","); got != expected {
+			t.Errorf("Expected rollback file events %s, got %s", expected, got)
+		}
+	})
+
+	t.Run("restart fails", func(t *testing.T) {
+		fakeCommands(t, map[string]string{"rsyslogd": "exit 0", "systemctl": "exit 1"})
+		if err := installRsyslogConf(&ServiceConfig{}, path, "v3"); err == nil {
+			t.Fatal("Expected restart error")
+		}
+		if content, _ := os.ReadFile(path); string(content) != "v1" {
+			t.Errorf("Expected previous content to be restored, got %q", content)
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package systemd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SliceConfig describes a slice unit used to group several services under
+// shared, aggregate resource limits (e.g. "ourteam.slice").
+type SliceConfig struct {
+	Name        string // Slice name, with or without the ".slice" suffix
+	Parent      string // Optional parent slice (e.g. "company.slice"); prefixes the unit name
+	Description string // Unit description (defaults to the slice name)
+
+	// Aggregate resource limits for all units in the slice
+	MemoryMax  string // Byte size, percentage or "infinity"
+	MemoryHigh string // Byte size, percentage or "infinity"
+	CPUQuota   int    // Percentage of one CPU (0 to leave unset)
+	CPUWeight  int    // Relative CPU weight 1-10000 (0 to leave unset)
+	IOWeight   int    // Relative IO weight 1-10000 (0 to leave unset)
+	TasksMax   string // Task count, percentage or "infinity"
+
+	SliceLines []string // Additional lines to append to [Slice] section
+}
+
+// UnitName returns the full slice unit name. When Parent is set, the parent's
+// name is used as a prefix so systemd places the slice below it in the hierarchy
+// (e.g. Parent "company.slice" and Name "ourteam" yield "company-ourteam.slice").
+func (s *SliceConfig) UnitName() string {
+	name := strings.TrimSuffix(s.Name, ".slice")
+	if parent := strings.TrimSuffix(s.Parent, ".slice"); parent != "" && parent != "-" &&
+		!strings.HasPrefix(name, parent+"-") {
+		name = parent + "-" + name
+	}
+	return name + ".slice"
+}
+
+// WithSliceUnit declares a slice unit that Install writes next to the service
+// unit file, and places the service in it via Slice=. Uninstall removes the slice
+// only when no other installed unit still references it.
+func WithSliceUnit(s SliceConfig) ServiceOpt {
+	return func(c *ServiceConfig) {
+		if _, err := s.directives(); err != nil {
+			c.invalid("Slice", err)
+			return
+		}
+		c.Slice = &s
+		c.ServiceLines = append(c.ServiceLines, "Slice="+s.UnitName())
+	}
+}
+
+// directives validates the slice limits and returns the complete [Slice] section lines.
+func (s *SliceConfig) directives() ([]string, error) {
+	if !sliceRe.MatchString(s.UnitName()) || strings.TrimSuffix(s.Name, ".slice") == "" {
+		return nil, fmt.Errorf("invalid slice name %q", s.Name)
+	}
+
+	// Reuse the service resource options so slices validate identically
+	var probe ServiceConfig
+	if s.MemoryHigh != "" {
+		WithMemoryHigh(s.MemoryHigh)(&probe)
+	}
+	if s.MemoryMax != "" {
+		WithMemoryMax(s.MemoryMax)(&probe)
+	}
+	if s.CPUQuota != 0 {
+		WithCPUQuota(s.CPUQuota)(&probe)
+	}
+	if s.CPUWeight != 0 {
+		WithCPUWeight(s.CPUWeight)(&probe)
+	}
+	if s.IOWeight != 0 {
+		WithIOWeight(s.IOWeight)(&probe)
+	}
+	if s.TasksMax != "" {
+		WithTasksMax(s.TasksMax)(&probe)
+	}
+	if err := probe.Validate(); err != nil {
+		return nil, fmt.Errorf("slice %s: %w", s.UnitName(), err)
+	}
+
+	return append(probe.ServiceLines, s.SliceLines...), nil
+}
+
+// validateSlice rejects service units placed in more than one slice, e.g. by
+// combining WithSlice and WithSliceUnit.
+func validateSlice(c *ServiceConfig) []error {
+	var slices []string
+	for _, line := range c.ServiceLines {
+		if name, value, ok := strings.Cut(line, "="); ok && strings.TrimSpace(name) == "Slice" {
+			slices = append(slices, strings.TrimSpace(value))
+		}
+	}
+	if len(slices) > 1 {
+		return []error{fmt.Errorf("Slice: service placed in several slices: %s", strings.Join(slices, ", "))}
+	}
+	return nil
+}
+
+// slicePath returns the file path of the configured slice unit, placed
+// in the same directory as the service unit file.
+func slicePath(c *ServiceConfig) string {
+	return filepath.Join(filepath.Dir(c.SystemdFile), c.Slice.UnitName())
+}
+
+// writeSliceUnit creates the slice unit file for the configured slice.
+func writeSliceUnit(c *ServiceConfig) error {
+	s := c.Slice
+	description := s.Description
+	if description == "" {
+		description = s.UnitName()
+	}
+
+	lines, err := s.directives()
+	if err != nil {
+		return err
+	}
+	extraLines := ""
+	if len(lines) > 0 {
+		extraLines = strings.Join(lines, "\n") + "\n"
+	}
+
+	unit := fmt.Sprintf(`[Unit]
+Description=%s
+Before=slices.target
+
+[Slice]
+%s`, description, extraLines)
+
+	return c.writeFile(slicePath(c), []byte(unit))
+}
+
+// sliceInUse reports whether any unit file in dir still references the slice,
+// either through a Slice= directive or by being a child slice below it.
+func sliceInUse(dir, slice string) (bool, error) {
+	childPrefix := strings.TrimSuffix(slice, ".slice") + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && name != slice && strings.HasPrefix(name, childPrefix) && strings.HasSuffix(name, ".slice") {
+			return true, nil
+		}
+	}
+	return directiveInUse(dir, "Slice", slice)
+}
+
+// directiveInUse reports whether any unit file in dir, or any drop-in in its
+// <unit>.d directories, contains key=value.
+func directiveInUse(dir, key, value string) (bool, error) {
+	paths, err := unitFiles(dir)
+	if err != nil {
+		return false, err
+	}
+
+	for _, path := range paths {
+		found, err := unitHasDirective(path, key, value)
+		if err != nil {
+			return false, err
+		}
+		if found {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// unitFiles returns the regular unit files in dir followed by the *.conf
+// drop-ins of its <unit>.d directories. Symlinks are followed; dangling ones,
+// such as units removed while scanning, are skipped.
+func unitFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files, dropInDirs []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case info.IsDir() && strings.HasSuffix(entry.Name(), ".d"):
+			dropInDirs = append(dropInDirs, path)
+		case info.Mode().IsRegular():
+			files = append(files, path)
+		}
+	}
+
+	for _, dropInDir := range dropInDirs {
+		matches, err := filepath.Glob(filepath.Join(dropInDir, "*.conf"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range matches {
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				files = append(files, path)
+			}
+		}
+	}
+	return files, nil
+}
+
+// unitHasDirective reports whether the unit file at path contains key=value.
+func unitHasDirective(path, key, value string) (bool, error) {
+	f, err := os.Open(path) // #nosec G304
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+
+	want := key + "=" + value
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == want {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
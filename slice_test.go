@@ -0,0 +1,147 @@
+package systemd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSliceUnitName tests slice unit naming with and without a parent
+func TestSliceUnitName(t *testing.T) {
+	tests := []struct {
+		name     string
+		slice    SliceConfig
+		expected string
+	}{
+		{"plain", SliceConfig{Name: "ourteam"}, "ourteam.slice"},
+		{"with suffix", SliceConfig{Name: "ourteam.slice"}, "ourteam.slice"},
+		{"with parent", SliceConfig{Name: "ourteam", Parent: "company.slice"}, "company-ourteam.slice"},
+		{"already prefixed", SliceConfig{Name: "company-ourteam.slice", Parent: "company.slice"}, "company-ourteam.slice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.slice.UnitName(); got != tt.expected {
+				t.Errorf("UnitName() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestWithSliceUnit tests the slice option and its validation
+func TestWithSliceUnit(t *testing.T) {
+	cfg := ServiceConfig{}
+	WithSliceUnit(SliceConfig{Name: "ourteam", MemoryMax: "4G", CPUWeight: 50})(&cfg)
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	if cfg.Slice == nil || cfg.Slice.UnitName() != "ourteam.slice" {
+		t.Fatalf("Expected slice ourteam.slice to be set, got %+v", cfg.Slice)
+	}
+	if len(cfg.ServiceLines) != 1 || cfg.ServiceLines[0] != "Slice=ourteam.slice" {
+		t.Errorf("Expected ServiceLines to contain 'Slice=ourteam.slice', got %v", cfg.ServiceLines)
+	}
+
+	invalid := ServiceConfig{}
+	WithSliceUnit(SliceConfig{Name: "ourteam", CPUWeight: 20000})(&invalid)
+	if err := invalid.Validate(); err == nil {
+		t.Error("Expected validation error for out-of-range CPUWeight")
+	}
+	if invalid.Slice != nil {
+		t.Error("Expected invalid slice not to be set")
+	}
+
+	twice := ServiceConfig{}
+	WithSlice("system.slice")(&twice)
+	WithSliceUnit(SliceConfig{Name: "ourteam"})(&twice)
+	if err := twice.Validate(); err == nil {
+		t.Error("Expected validation error for a service placed in two slices")
+	}
+}
+
+// TestWriteSliceUnit tests slice unit file generation
+func TestWriteSliceUnit(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := ServiceConfig{SystemdFile: filepath.Join(tempDir, "test.service")}
+	WithSliceUnit(SliceConfig{
+		Name:       "ourteam",
+		Parent:     "company.slice",
+		MemoryHigh: "3G",
+		MemoryMax:  "4G",
+		CPUQuota:   300,
+		SliceLines: []string{"IOAccounting=yes"},
+	})(&cfg)
+
+	if err := writeSliceUnit(&cfg); err != nil {
+		t.Fatalf("Failed to write slice unit: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "company-ourteam.slice"))
+	if err != nil {
+		t.Fatalf("Failed to read slice unit: %v", err)
+	}
+
+	expected := "[Unit]\nDescription=company-ourteam.slice\nBefore=slices.target\n\n[Slice]\n" +
+		"MemoryHigh=3G\nMemoryMax=4G\nCPUQuota=300%\nIOAccounting=yes\n"
+	if string(content) != expected {
+		t.Errorf("Slice unit content mismatch.\nExpected:\n%s\nGot:\n%s", expected, string(content))
+	}
+}
+
+// TestSliceInUse tests detection of units still referencing a slice
+func TestSliceInUse(t *testing.T) {
+	tempDir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("ourteam.slice", "[Slice]\nMemoryMax=4G\n")
+	write("other.service", "[Service]\nSlice=system.slice\n")
+
+	inUse, err := sliceInUse(tempDir, "ourteam.slice")
+	if err != nil {
+		t.Fatalf("sliceInUse failed: %v", err)
+	}
+	if inUse {
+		t.Error("Expected slice not to be in use")
+	}
+
+	write("api.service", "[Service]\nExecStart=/usr/bin/api\nSlice=ourteam.slice\n")
+	if inUse, _ = sliceInUse(tempDir, "ourteam.slice"); !inUse {
+		t.Error("Expected slice to be in use by api.service")
+	}
+
+	if err := os.Remove(filepath.Join(tempDir, "api.service")); err != nil {
+		t.Fatal(err)
+	}
+	write("ourteam-db.slice", "[Slice]\n")
+	if inUse, _ = sliceInUse(tempDir, "ourteam.slice"); !inUse {
+		t.Error("Expected slice to be in use by child slice")
+	}
+	if err := os.Remove(filepath.Join(tempDir, "ourteam-db.slice")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Symlinked units are followed and dangling links skipped
+	if err := os.Symlink(filepath.Join(tempDir, "missing.service"), filepath.Join(tempDir, "gone.service")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(t.TempDir(), filepath.Join(tempDir, "dir.service")); err != nil {
+		t.Fatal(err)
+	}
+	if inUse, err = sliceInUse(tempDir, "ourteam.slice"); err != nil || inUse {
+		t.Errorf("Expected slice not to be in use, got %v (%v)", inUse, err)
+	}
+
+	if err := os.Mkdir(filepath.Join(tempDir, "worker.service.d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write("worker.service.d/10-slice.conf", "[Service]\nSlice=ourteam.slice\n")
+	if inUse, err = sliceInUse(tempDir, "ourteam.slice"); err != nil || !inUse {
+		t.Errorf("Expected slice to be in use by drop-in, got %v (%v)", inUse, err)
+	}
+}
@@ -0,0 +1,135 @@
+package systemd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TmpfilesType is the type of a tmpfiles.d entry.
+type TmpfilesType string
+
+const (
+	TmpfilesDir             TmpfilesType = "d" // Create a directory, cleaning entries older than Age
+	TmpfilesDirRemove       TmpfilesType = "D" // Like TmpfilesDir, but emptied on --remove (boot)
+	TmpfilesFile            TmpfilesType = "f" // Create a file with Argument as content if missing
+	TmpfilesSymlink         TmpfilesType = "L" // Create a symlink to Argument
+	TmpfilesAdjust          TmpfilesType = "z" // Adjust mode and ownership of an existing path
+	TmpfilesAdjustRecursive TmpfilesType = "Z" // Adjust mode and ownership recursively
+)
+
+// TmpfilesEntry is a path declared in the service's tmpfiles.d configuration.
+// Empty fields are rendered as "-", keeping systemd-tmpfiles' defaults.
+type TmpfilesEntry struct {
+	Type     TmpfilesType // Entry type
+	Path     string       // Absolute path
+	Mode     os.FileMode  // Permission bits (0 for the default)
+	User     string       // Owning user
+	Group    string       // Owning group
+	Age      string       // Cleanup age of directory contents, e.g. "10d" (TmpfilesDir and TmpfilesDirRemove)
+	Argument string       // Symlink target or initial file content
+}
+
+// WithTmpfiles declares paths in /etc/tmpfiles.d/<UniqueName>.conf. Install
+// creates them with systemd-tmpfiles --create; Uninstall removes the file but
+// keeps the paths.
+func WithTmpfiles(entries ...TmpfilesEntry) ServiceOpt {
+	return func(c *ServiceConfig) {
+		for _, e := range entries {
+			if err := e.validate(); err != nil {
+				c.invalid("tmpfiles", err)
+				continue
+			}
+			c.TmpfilesEntries = append(c.TmpfilesEntries, e)
+		}
+	}
+}
+
+// validate checks the entry fields against its type.
+func (e *TmpfilesEntry) validate() error {
+	switch e.Type {
+	case TmpfilesDir, TmpfilesDirRemove, TmpfilesFile, TmpfilesSymlink, TmpfilesAdjust, TmpfilesAdjustRecursive:
+	default:
+		return fmt.Errorf("unknown entry type %q", e.Type)
+	}
+	if !filepath.IsAbs(e.Path) || filepath.Clean(e.Path) != e.Path || strings.ContainsAny(e.Path, " \t\n") {
+		return fmt.Errorf("%s: invalid path %q", e.Type, e.Path)
+	}
+	if e.Mode&^os.ModePerm != 0 {
+		return fmt.Errorf("%s: invalid mode %o", e.Path, e.Mode)
+	}
+	if strings.ContainsAny(e.User+e.Group, " \t\n") {
+		return fmt.Errorf("%s: invalid owner %q:%q", e.Path, e.User, e.Group)
+	}
+	if e.Age != "" {
+		if e.Type != TmpfilesDir && e.Type != TmpfilesDirRemove {
+			return fmt.Errorf("%s: age is only supported for directories", e.Path)
+		}
+		if !timeSpanRe.MatchString(e.Age) || strings.ContainsAny(e.Age, " \t") {
+			return fmt.Errorf("%s: invalid age %q", e.Path, e.Age)
+		}
+	}
+	switch {
+	case e.Type == TmpfilesSymlink && e.Argument == "":
+		return fmt.Errorf("%s: symlink requires a target", e.Path)
+	case e.Argument != "" && e.Type != TmpfilesSymlink && e.Type != TmpfilesFile:
+		return fmt.Errorf("%s: argument is only supported for files and symlinks", e.Path)
+	case strings.Contains(e.Argument, "\n"):
+		return fmt.Errorf("%s: argument must be a single line", e.Path)
+	}
+	return nil
+}
+
+// render returns the tmpfiles.d line of the entry.
+func (e *TmpfilesEntry) render() string {
+	field := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	mode := "-"
+	if e.Mode != 0 {
+		mode = fmt.Sprintf("%04o", e.Mode)
+	}
+	line := fmt.Sprintf("%s %s %s %s %s %s", e.Type, e.Path, mode, field(e.User), field(e.Group), field(e.Age))
+	if e.Argument != "" {
+		line += " " + e.Argument
+	}
+	return line
+}
+
+// usesTmpfiles reports whether the service has a tmpfiles.d configuration.
+func usesTmpfiles(c *ServiceConfig) bool {
+	return len(c.TmpfilesEntries) > 0 || (c.LogDir != "" && c.LogDirSetup != nil && c.LogDirSetup.Tmpfiles)
+}
+
+// tmpfilesPath returns the tmpfiles.d configuration path of the service.
+func tmpfilesPath(c *ServiceConfig) string {
+	return filepath.Join("/etc/tmpfiles.d", c.UniqueName+".conf")
+}
+
+// renderTmpfilesConf generates the tmpfiles.d configuration of the service.
+func renderTmpfilesConf(c *ServiceConfig) string {
+	lines := []string{"# Paths of " + c.ServiceName}
+	if c.LogDir != "" && c.LogDirSetup != nil && c.LogDirSetup.Tmpfiles {
+		lines = append(lines, renderLogDirTmpfiles(c)...)
+	}
+	for _, e := range c.TmpfilesEntries {
+		lines = append(lines, e.render())
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// writeTmpfilesConf writes the tmpfiles.d configuration and creates the declared paths.
+func writeTmpfilesConf(c *ServiceConfig) error {
+	path := tmpfilesPath(c)
+	if err := c.writeFile(path, []byte(renderTmpfilesConf(c))); err != nil {
+		return fmt.Errorf("failed to write tmpfiles.d configuration: %w", err)
+	}
+	if err := c.execCommand("systemd-tmpfiles", "--create", path); err != nil {
+		return fmt.Errorf("failed to apply %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package systemd
+
+import "testing"
+
+// TestRenderTmpfilesConf tests the generated tmpfiles.d configuration
+func TestRenderTmpfilesConf(t *testing.T) {
+	cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "",
+		WithTmpfiles(
+			TmpfilesEntry{Type: TmpfilesDir, Path: "/run/myapp", Mode: 0o750, User: "svc", Group: "svc"},
+			TmpfilesEntry{Type: TmpfilesDir, Path: "/var/cache/myapp", Mode: 0o700, User: "svc", Group: "svc", Age: "7d"},
+			TmpfilesEntry{Type: TmpfilesSymlink, Path: "/var/lib/myapp/current", Argument: "/opt/myapp/v2"},
+			TmpfilesEntry{Type: TmpfilesAdjustRecursive, Path: "/var/lib/myapp", User: "svc"},
+		))
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+
+	expected := `# Paths of bin-myapp.service
+d /run/myapp 0750 svc svc -
+d /var/cache/myapp 0700 svc svc 7d
+L /var/lib/myapp/current - - - - /opt/myapp/v2
+Z /var/lib/myapp - svc - -
+`
+	if got := renderTmpfilesConf(&cfg); got != expected {
+		t.Errorf("Expected tmpfiles configuration:\n%s\ngot:\n%s", expected, got)
+	}
+	if !usesTmpfiles(&cfg) || tmpfilesPath(&cfg) != "/etc/tmpfiles.d/bin-myapp.conf" {
+		t.Errorf("Expected tmpfiles configuration at /etc/tmpfiles.d/bin-myapp.conf, got %s", tmpfilesPath(&cfg))
+	}
+}
+
+// TestTmpfilesEntryValidation tests rejected tmpfiles.d entries
+func TestTmpfilesEntryValidation(t *testing.T) {
+	invalid := []TmpfilesEntry{
+		{Type: "x", Path: "/run/myapp"},
+		{Type: TmpfilesDir, Path: "run/myapp"},
+		{Type: TmpfilesDir, Path: "/run/../myapp"},
+		{Type: TmpfilesDir, Path: "/run/my app"},
+		{Type: TmpfilesDir, Path: "/run/myapp", Mode: 0o20000},
+		{Type: TmpfilesDir, Path: "/run/myapp", Age: "soon"},
+		{Type: TmpfilesFile, Path: "/run/myapp.pid", Age: "1d"},
+		{Type: TmpfilesSymlink, Path: "/run/myapp"},
+		{Type: TmpfilesDir, Path: "/run/myapp", Argument: "x"},
+	}
+	for _, e := range invalid {
+		cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "", WithTmpfiles(e))
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected validation error for %+v", e)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+)
+
+// userUnitMode is the mode of the user unit directory created by Install.
+const userUnitMode os.FileMode = 0o755
+
+// WithUserScope manages the service in the calling user's service manager
+// (systemctl --user), which does not require root. The unit is written to
+// ~/.config/systemd/user/ unless SystemdFile is set, and wanted by default.target.
+//
+// Steps that need root are skipped: account creation, tmpfiles.d, LogDir,
+// rsyslog, logrotate and journal namespace configuration. DynamicUser and
+// sysusers.d accounts are rejected.
+func WithUserScope() Option {
+	return func(m *Manager) { m.cfg.userScope = true }
+}
+
+// WithLinger enables lingering for the calling user during Install
+// (loginctl enable-linger), so user services start at boot and survive logout.
+// It only has effect together with WithUserScope.
+func WithLinger() Option {
+	return func(m *Manager) { m.linger = true }
+}
+
+// userUnitDir returns the directory holding the calling user's unit files.
+func userUnitDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine user unit directory: %w", err)
+	}
+	return filepath.Join(dir, "systemd", "user"), nil
+}
+
+// systemctl runs systemctl against the service manager of the Manager's scope.
+func (m *Manager) systemctl(args ...string) error {
+	if m.cfg.userScope {
+		args = append([]string{"--user"}, args...)
+	}
+	return m.cfg.execCommand("systemctl", args...)
+}
+
+// checkUserScope verifies that the configuration can be installed without root.
+func checkUserScope(c *ServiceConfig) error {
+	var errs []error
+	if c.SystemdFile == "" {
+		_, err := userUnitDir()
+		errs = append(errs, err)
+	}
+	if c.DynamicUser {
+		errs = append(errs, errors.New("DynamicUser is not supported in user scope"))
+	}
+	if c.UseSysusers {
+		errs = append(errs, errors.New("sysusers.d accounts are not supported in user scope"))
+	}
+	if c.LogNamespace != nil {
+		errs = append(errs, errors.New("journal namespaces are not supported in user scope"))
+	}
+	return errors.Join(errs...)
+}
+
+// installUserScope creates the user unit directory and enables lingering if requested.
+func (m *Manager) installUserScope() error {
+	c := m.cfg
+	if err := os.MkdirAll(filepath.Dir(c.SystemdFile), userUnitMode); err != nil {
+		return fmt.Errorf("failed to create user unit directory: %w", err)
+	}
+
+	if m.linger {
+		u, err := user.Current()
+		if err != nil {
+			return err
+		}
+		if err := c.execCommand("loginctl", "enable-linger", u.Username); err != nil {
+			return err
+		}
+		m.infof("Lingering enabled for user %s", u.Username)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package systemd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestUserScopeInstall tests installation into the user's service manager
+func TestUserScopeInstall(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	dir := fakeCommands(t, map[string]string{"systemctl": "exit 0", "loginctl": "exit 0"})
+
+	cfg := NewServiceConfig("nobody", "nogroup", "/usr/bin/myapp", filepath.Join(t.TempDir(), "log"),
+		WithStream("app", "app.log"), WithLogrotate())
+	m := NewManager(&cfg, WithUserScope(), WithLinger())
+
+	unitPath := filepath.Join(configHome, "systemd", "user", "bin-myapp.service")
+	if m.cfg.SystemdFile != unitPath {
+		t.Fatalf("Expected unit path %s, got %s", unitPath, m.cfg.SystemdFile)
+	}
+	if err := m.Install(); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	unit, err := os.ReadFile(unitPath)
+	if err != nil {
+		t.Fatalf("Unit file not written: %v", err)
+	}
+	if strings.Contains(string(unit), "User=") || !strings.Contains(string(unit), "WantedBy=default.target") {
+		t.Errorf("Unexpected user unit:\n%s", unit)
+	}
+	if _, err := os.Stat(cfg.LogDir); !os.IsNotExist(err) {
+		t.Errorf("Expected LogDir to be skipped in user scope, got %v", err)
+	}
+
+	calls := fakeCalls(t, dir)
+	for _, want := range []string{"loginctl enable-linger ", "systemctl --user daemon-reload", "systemctl --user enable bin-myapp.service", "systemctl --user start bin-myapp.service"} {
+		if !strings.Contains(calls, want) {
+			t.Errorf("Expected %q in calls:\n%s", want, calls)
+		}
+	}
+
+	if err := m.Uninstall(); err != nil {
+		t.Fatalf("Uninstall failed: %v", err)
+	}
+	if _, err := os.Stat(unitPath); !os.IsNotExist(err) {
+		t.Error("Expected unit file to be removed")
+	}
+	if calls = fakeCalls(t, dir); strings.Contains(calls, "rsyslog") || !strings.Contains(calls, "systemctl --user disable bin-myapp.service") {
+		t.Errorf("Unexpected uninstall calls:\n%s", calls)
+	}
+}
+
+// TestUserScopeRejected tests configurations that require root
+func TestUserScopeRejected(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	fakeCommands(t, map[string]string{"systemctl": "exit 0"})
+
+	for _, opt := range []ServiceOpt{WithDynamicUser(), WithSysusers(AccountConfig{}),
+		WithLogNamespace(JournalNamespace{Name: "svc"})} {
+		cfg := NewServiceConfig("svc", "svc", "/usr/bin/myapp", "", opt)
+		if err := NewManager(&cfg, WithUserScope()).Install(); err == nil || !strings.Contains(err.Error(), "not supported in user scope") {
+			t.Errorf("Expected Install to reject the configuration in user scope, got %v", err)
+		}
+	}
+}
+
+// TestJournalctlArgsUserScope tests reading logs of a user service
+func TestJournalctlArgsUserScope(t *testing.T) {
+	cfg := &ServiceConfig{ServiceName: "myapp.service", userScope: true}
+	args, err := journalctlArgs(cfg, LogOptions{})
+	if err != nil || args[0] != "--user-unit" {
+		t.Errorf("Expected --user-unit, got %v (%v)", args, err)
+	}
+}
@@ -0,0 +1,148 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// defaultWatchInterval is how often Watch polls systemctl unless configured otherwise.
+const defaultWatchInterval = time.Second
+
+// UnitEventType identifies what changed in a UnitEvent.
+type UnitEventType string
+
+// Unit event types emitted by Watch.
+const (
+	UnitStateChanged UnitEventType = "state-changed" // ActiveState or SubState changed
+	UnitRestarted    UnitEventType = "restarted"     // A new invocation of the service started
+	UnitRestartCount UnitEventType = "restart-count" // NRestarts increased (automatic restart by Restart=)
+)
+
+// unitStateProperties are the properties Watch reads for each snapshot.
+var unitStateProperties = []string{"ActiveState", "SubState", "NRestarts", "MainPID", "InvocationID"}
+
+// UnitState is a snapshot of the service's runtime state.
+type UnitState struct {
+	ActiveState  string // e.g. "active", "activating", "failed"
+	SubState     string // e.g. "running", "auto-restart", "dead"
+	NRestarts    int    // Number of automatic restarts
+	MainPID      int    // PID of the main process, 0 if none
+	InvocationID string // Identifier of the current invocation, changes on every start
+}
+
+// UnitEvent reports a change of the service's state.
+type UnitEvent struct {
+	Type     UnitEventType
+	Time     time.Time
+	Unit     string
+	State    UnitState // State after the change
+	Previous UnitState // State before the change
+}
+
+// WithWatchInterval sets how often Watch polls the service state with systemctl.
+// It has no effect with WithDBus, where systemd signals changes.
+func WithWatchInterval(d time.Duration) Option {
+	return func(m *Manager) { m.watchInterval = d }
+}
+
+// Watch emits an event whenever the service's ActiveState or SubState changes,
+// a new invocation starts, or NRestarts increases. By default the state is polled
+// with systemctl show; with WithDBus it is re-read when systemd signals a
+// property change.
+//
+// Transitions completing between two polls are not observed. The events channel
+// is closed when ctx is cancelled or watching fails; the error channel then
+// delivers the failure, if any.
+func (m *Manager) Watch(ctx context.Context) (<-chan UnitEvent, <-chan error) {
+	events := make(chan UnitEvent)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(events)
+		if err := m.watch(ctx, events); err != nil && ctx.Err() == nil {
+			errs <- err
+		}
+	}()
+
+	return events, errs
+}
+
+// watch delivers unit events until ctx is done or reading the state fails.
+func (m *Manager) watch(ctx context.Context, events chan<- UnitEvent) error {
+	b, err := m.openBackend()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = b.close() }()
+
+	interval := m.watchInterval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	unit := m.cfg.ServiceName
+
+	// Register for changes before the first snapshot so none is missed
+	changes, err := b.notify(ctx, unit, interval)
+	if err != nil {
+		return err
+	}
+	prev, err := readUnitState(b, unit)
+	if err != nil {
+		return err
+	}
+
+	for range changes {
+		cur, err := readUnitState(b, unit)
+		if err != nil {
+			return err
+		}
+		for _, t := range unitEventTypes(prev, cur) {
+			select {
+			case events <- UnitEvent{Type: t, Time: time.Now(), Unit: unit, State: cur, Previous: prev}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		prev = cur
+	}
+
+	if ctx.Err() == nil {
+		return errors.New("unit change notifications stopped")
+	}
+	return nil
+}
+
+// readUnitState reads a snapshot of the unit's state.
+func readUnitState(b backend, unit string) (UnitState, error) {
+	props, err := b.properties(unit, unitStateProperties...)
+	if err != nil {
+		return UnitState{}, fmt.Errorf("failed to read state of %s: %w", unit, err)
+	}
+	s := UnitState{
+		ActiveState:  props["ActiveState"],
+		SubState:     props["SubState"],
+		InvocationID: props["InvocationID"],
+	}
+	s.NRestarts, _ = strconv.Atoi(props["NRestarts"])
+	s.MainPID, _ = strconv.Atoi(props["MainPID"])
+	return s, nil
+}
+
+// unitEventTypes returns the events describing the change from prev to cur.
+func unitEventTypes(prev, cur UnitState) []UnitEventType {
+	var types []UnitEventType
+	if cur.ActiveState != prev.ActiveState || cur.SubState != prev.SubState {
+		types = append(types, UnitStateChanged)
+	}
+	if prev.InvocationID != "" && cur.InvocationID != "" && cur.InvocationID != prev.InvocationID {
+		types = append(types, UnitRestarted)
+	}
+	if cur.NRestarts > prev.NRestarts {
+		types = append(types, UnitRestartCount)
+	}
+	return types
+}
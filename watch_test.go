@@ -0,0 +1,147 @@
+package systemd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collectUnitEvents reads n events or fails after a timeout.
+func collectUnitEvents(t *testing.T, events <-chan UnitEvent, n int) []UnitEvent {
+	t.Helper()
+	var got []UnitEvent
+	timeout := time.After(5 * time.Second)
+	for len(got) < n {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatalf("Events closed after %d of %d events", len(got), n)
+			}
+			got = append(got, e)
+		case <-timeout:
+			t.Fatalf("Timed out after %d of %d events", len(got), n)
+		}
+	}
+	return got
+}
+
+// TestUnitEventTypes tests deriving events from two state snapshots
+func TestUnitEventTypes(t *testing.T) {
+	running := UnitState{ActiveState: "active", SubState: "running", NRestarts: 1, MainPID: 10, InvocationID: "a"}
+	tests := []struct {
+		name string
+		cur  UnitState
+		want []UnitEventType
+	}{
+		{"unchanged", running, nil},
+		{"pid only", UnitState{"active", "running", 1, 11, "a"}, nil},
+		{"substate", UnitState{"active", "reloading", 1, 10, "a"}, []UnitEventType{UnitStateChanged}},
+		{"auto restart", UnitState{"activating", "auto-restart", 2, 0, "a"}, []UnitEventType{UnitStateChanged, UnitRestartCount}},
+		{"manual restart", UnitState{"active", "running", 1, 12, "b"}, []UnitEventType{UnitRestarted}},
+		{"stopped", UnitState{"inactive", "dead", 1, 0, ""}, []UnitEventType{UnitStateChanged}},
+	}
+	for _, tt := range tests {
+		if got := unitEventTypes(running, tt.cur); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestWatchPolling tests events derived from successive systemctl show snapshots
+func TestWatchPolling(t *testing.T) {
+	stateDir := t.TempDir()
+	states := strings.Join([]string{
+		"ActiveState=active SubState=running NRestarts=0 MainPID=10 InvocationID=a",
+		"ActiveState=active SubState=running NRestarts=0 MainPID=10 InvocationID=a",
+		"ActiveState=activating SubState=auto-restart NRestarts=1 MainPID=0 InvocationID=a",
+		"ActiveState=active SubState=running NRestarts=1 MainPID=11 InvocationID=b",
+	}, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(stateDir, "states"), []byte(states), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Each call prints the next snapshot, repeating the last one
+	fakeCommands(t, map[string]string{"systemctl": `cd ` + stateDir + `
+n=$(cat count 2>/dev/null || echo 0); n=$((n+1)); echo $n > count
+[ $n -gt 4 ] && n=4
+sed -n "${n}p" states | tr ' ' '\n'`})
+
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"}, WithWatchInterval(10*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errs := m.Watch(ctx)
+
+	got := collectUnitEvents(t, events, 4)
+	want := []UnitEventType{UnitStateChanged, UnitRestartCount, UnitStateChanged, UnitRestarted}
+	for i, e := range got {
+		if e.Type != want[i] || e.Unit != "app.service" {
+			t.Errorf("Event %d: expected %s for app.service, got %s for %s", i, want[i], e.Type, e.Unit)
+		}
+	}
+	if got[1].State.NRestarts != 1 || got[1].Previous.NRestarts != 0 || got[1].State.SubState != "auto-restart" {
+		t.Errorf("Unexpected restart count event: %+v", got[1])
+	}
+	if got[3].State.MainPID != 11 || got[3].State.InvocationID != "b" {
+		t.Errorf("Unexpected restart event: %+v", got[3])
+	}
+
+	cancel()
+	for range events {
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("Expected no error after cancel, got %v", err)
+	}
+}
+
+// TestWatchError tests that a failing state read ends the watch with an error
+func TestWatchError(t *testing.T) {
+	fakeCommands(t, map[string]string{"systemctl": `echo "Failed to connect to bus" >&2; exit 1`})
+
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"}, WithWatchInterval(10*time.Millisecond))
+	events, errs := m.Watch(context.Background())
+	for range events {
+	}
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "failed to read state of app.service") {
+		t.Errorf("Expected state read error, got %v", err)
+	}
+}
+
+// TestWatchDBus tests events triggered by PropertiesChanged signals
+func TestWatchDBus(t *testing.T) {
+	addr := startDBusDaemon(t)
+	fake := startFakeSystemd(t, addr)
+
+	m := NewManager(&ServiceConfig{ServiceName: "app.service"}, WithBusAddress(addr))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, _ := m.Watch(ctx)
+
+	// InvocationID is read last, so the first snapshot is complete once it was served
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(fake.callLog(), "Get "+systemdUnitIface+" InvocationID") {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the first snapshot")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	err := fake.setProperties(map[string]dbusVariant{
+		"ActiveState": {Sig: "s", Value: "activating"},
+		"SubState":    {Sig: "s", Value: "auto-restart"},
+		"NRestarts":   {Sig: "u", Value: uint32(4)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := collectUnitEvents(t, events, 2)
+	if got[0].Type != UnitStateChanged || got[1].Type != UnitRestartCount {
+		t.Errorf("Expected state-changed and restart-count, got %s and %s", got[0].Type, got[1].Type)
+	}
+	want := UnitState{ActiveState: "activating", SubState: "auto-restart", NRestarts: 4, MainPID: 10, InvocationID: "0a"}
+	if got[0].State != want || got[0].Previous.NRestarts != 3 {
+		t.Errorf("Expected state %+v after 3 restarts, got %+v after %d", want, got[0].State, got[0].Previous.NRestarts)
+	}
+}